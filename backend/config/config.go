@@ -1,17 +1,27 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	DB     DBConfig
-	JWT    JWTConfig
-	Server ServerConfig
-	SMTP   SMTPConfig
+	DB       DBConfig
+	JWT      JWTConfig
+	Server   ServerConfig
+	SMTP     SMTPConfig
+	Mail     MailConfig
+	Auth     AuthConfig
+	Login    LoginThrottleConfig
+	OIDC     OIDCConfig
+	Password PasswordPolicyConfig
+	Hash     PasswordHashConfig
+	Places   PlacesConfig
 }
 
 type DBConfig struct {
@@ -22,10 +32,29 @@ type DBConfig struct {
 	Name     string
 }
 
+// placeholderJWTSecrets are the secrets shipped as defaults or examples,
+// which anyone can use to forge tokens.
+var placeholderJWTSecrets = map[string]bool{
+	"default-secret-key":                         true,
+	"your-super-secret-key-change-in-production": true,
+}
+
+// minJWTSecretLength is the shortest secret accepted, 256 bits as hex.
+const minJWTSecretLength = 32
+
 type JWTConfig struct {
+	// Secret signs the single-purpose tokens sent in emails, and access
+	// tokens when KeysDir is empty
 	Secret        string
 	AccessExpiry  time.Duration
 	RefreshExpiry time.Duration
+	// KeysDir holds RSA/Ed25519 PEM keys named <kid>.pem; when empty,
+	// access tokens are signed with HS256 using Secret
+	KeysDir     string
+	ActiveKeyID string
+	// RevocationCacheTTL is how long token revocation state is cached;
+	// revocations made by other instances apply after at most this long
+	RevocationCacheTTL time.Duration
 }
 
 type ServerConfig struct {
@@ -39,13 +68,150 @@ type SMTPConfig struct {
 	Password string
 }
 
+type MailConfig struct {
+	Driver    string
+	From      string
+	OutboxDir string
+	AppURL    string
+}
+
+type AuthConfig struct {
+	EmailVerificationExpiry  time.Duration
+	TwoFactorChallengeExpiry time.Duration
+	TOTPIssuer               string
+	// EmailChangeExpiry limits the confirmation link sent to a new address,
+	// EmailChangeUndoExpiry the link that lets the old address revert it
+	EmailChangeExpiry     time.Duration
+	EmailChangeUndoExpiry time.Duration
+	// MagicLinkExpiry limits passwordless login links; each email address
+	// and IP may request MagicLinkEmailLimit and MagicLinkIPLimit links per
+	// MailRequestWindow
+	MagicLinkExpiry     time.Duration
+	MagicLinkEmailLimit int
+	MagicLinkIPLimit    int
+	// AccountDeletionGracePeriod is how long a deleted account can be restored
+	AccountDeletionGracePeriod time.Duration
+	// RequireVerifiedEmail keeps unverified accounts from posting reviews
+	RequireVerifiedEmail bool
+	// MailRequestEmailLimit and MailRequestIPLimit cap, per
+	// MailRequestWindow, the password reset and verification emails an
+	// address or IP may ask for; each kind of email is counted on its own
+	MailRequestEmailLimit int
+	MailRequestIPLimit    int
+	MailRequestWindow     time.Duration
+	// ReauthenticationExpiry limits the codes that let accounts without a
+	// password confirm sensitive changes
+	ReauthenticationExpiry time.Duration
+	// AdminEmails are promoted to admin on startup if the accounts exist
+	AdminEmails []string
+}
+
+// LoginThrottleConfig controls brute-force protection of the login endpoint.
+// Thresholds apply per email address; per IP they are multiplied by
+// IPMultiplier.
+type LoginThrottleConfig struct {
+	Store        string
+	DelayAfter   int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	LockAfter    int
+	LockDuration time.Duration
+	Window       time.Duration
+	IPMultiplier int
+}
+
+// OIDCConfig lists the OpenID Connect providers users can sign in with.
+// Providers are named in OIDC_PROVIDERS and configured through
+// OIDC_<NAME>_ISSUER, _CLIENT_ID, _CLIENT_SECRET and _SCOPES.
+type OIDCConfig struct {
+	// RedirectBaseURL is the public URL of this backend; callbacks go to
+	// <RedirectBaseURL>/api/auth/oidc/<provider>/callback
+	RedirectBaseURL string
+	StateExpiry     time.Duration
+	Providers       map[string]OIDCProviderConfig
+}
+
+// PasswordPolicyConfig sets the requirements for new passwords.
+// MinEntropy is the estimated strength in bits. BreachedPasswordsDir holds
+// breached password hashes split by SHA-1 prefix: files named
+// <5 hex chars>.txt with SUFFIX:COUNT lines, as served by the Pwned
+// Passwords range API. Screening is off when it is empty.
+type PasswordPolicyConfig struct {
+	MinLength            int
+	MaxLength            int
+	MinEntropy           float64
+	BreachedPasswordsDir string
+	// BreachedMinCount is how often a password must appear in the corpus
+	// to be rejected
+	BreachedMinCount int
+}
+
+// PasswordHashConfig selects how new password hashes are made. Hashes
+// made with another algorithm or weaker parameters keep working and are
+// replaced on the next successful login.
+type PasswordHashConfig struct {
+	// Algorithm is "argon2id" or "bcrypt"
+	Algorithm  string
+	BcryptCost int
+	// Argon2Memory is in KiB
+	Argon2Memory  uint32
+	Argon2Time    uint32
+	Argon2Threads uint8
+}
+
+type PlacesConfig struct {
+	// GeoBackend is "sql" for the plain Postgres nearby search or
+	// "postgis" once database/optional/postgis_places.sql is applied
+	GeoBackend string
+}
+
+type OIDCProviderConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
 func Load() (*Config, error) {
 	godotenv.Load()
 
 	accessExpiry, _ := time.ParseDuration(getEnv("JWT_ACCESS_EXPIRY", "15m"))
 	refreshExpiry, _ := time.ParseDuration(getEnv("JWT_REFRESH_EXPIRY", "168h"))
+	revocationCacheTTL, _ := time.ParseDuration(getEnv("JWT_REVOCATION_CACHE_TTL", "5s"))
+	verificationExpiry, _ := time.ParseDuration(getEnv("EMAIL_VERIFICATION_EXPIRY", "48h"))
+	emailChangeExpiry, _ := time.ParseDuration(getEnv("EMAIL_CHANGE_EXPIRY", "24h"))
+	emailChangeUndoExpiry, _ := time.ParseDuration(getEnv("EMAIL_CHANGE_UNDO_EXPIRY", "168h"))
+	deletionGracePeriod, _ := time.ParseDuration(getEnv("ACCOUNT_DELETION_GRACE_PERIOD", "720h"))
+	magicLinkExpiry, _ := time.ParseDuration(getEnv("MAGIC_LINK_EXPIRY", "15m"))
+	magicLinkEmailLimit, _ := strconv.Atoi(getEnv("MAGIC_LINK_EMAIL_LIMIT", "3"))
+	magicLinkIPLimit, _ := strconv.Atoi(getEnv("MAGIC_LINK_IP_LIMIT", "20"))
+	twoFactorChallengeExpiry, _ := time.ParseDuration(getEnv("TWO_FACTOR_CHALLENGE_EXPIRY", "5m"))
+	requireVerifiedEmail, _ := strconv.ParseBool(getEnv("REQUIRE_VERIFIED_EMAIL", "false"))
+	mailRequestEmailLimit, _ := strconv.Atoi(getEnv("MAIL_REQUEST_EMAIL_LIMIT", "3"))
+	mailRequestIPLimit, _ := strconv.Atoi(getEnv("MAIL_REQUEST_IP_LIMIT", "20"))
+	mailRequestWindow, _ := time.ParseDuration(getEnv("MAIL_REQUEST_WINDOW", "1h"))
+	reauthenticationExpiry, _ := time.ParseDuration(getEnv("REAUTHENTICATION_EXPIRY", "10m"))
+	loginDelayAfter, _ := strconv.Atoi(getEnv("LOGIN_DELAY_AFTER", "3"))
+	loginBaseDelay, _ := time.ParseDuration(getEnv("LOGIN_BASE_DELAY", "1s"))
+	loginMaxDelay, _ := time.ParseDuration(getEnv("LOGIN_MAX_DELAY", "1m"))
+	loginLockAfter, _ := strconv.Atoi(getEnv("LOGIN_LOCK_AFTER", "10"))
+	loginLockDuration, _ := time.ParseDuration(getEnv("LOGIN_LOCK_DURATION", "15m"))
+	loginWindow, _ := time.ParseDuration(getEnv("LOGIN_ATTEMPT_WINDOW", "1h"))
+	loginIPMultiplier, _ := strconv.Atoi(getEnv("LOGIN_IP_MULTIPLIER", "5"))
+
+	passwordMinLength, _ := strconv.Atoi(getEnv("PASSWORD_MIN_LENGTH", "8"))
+	passwordMaxLength, _ := strconv.Atoi(getEnv("PASSWORD_MAX_LENGTH", "72"))
+	passwordMinEntropy, _ := strconv.ParseFloat(getEnv("PASSWORD_MIN_ENTROPY", "40"), 64)
+	passwordBreachedMinCount, _ := strconv.Atoi(getEnv("PASSWORD_BREACHED_MIN_COUNT", "1"))
+
+	bcryptCost, _ := strconv.Atoi(getEnv("BCRYPT_COST", "10"))
+	argon2Memory, _ := strconv.ParseUint(getEnv("ARGON2_MEMORY", "19456"), 10, 32)
+	argon2Time, _ := strconv.ParseUint(getEnv("ARGON2_TIME", "2"), 10, 32)
+	argon2Threads, _ := strconv.ParseUint(getEnv("ARGON2_THREADS", "1"), 10, 8)
 
-	return &Config{
+	oidcStateExpiry, _ := time.ParseDuration(getEnv("OIDC_STATE_EXPIRY", "10m"))
+
+	cfg := &Config{
 		DB: DBConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
 			Port:     getEnv("DB_PORT", "5432"),
@@ -54,9 +220,12 @@ func Load() (*Config, error) {
 			Name:     getEnv("DB_NAME", "sensory_navigator"),
 		},
 		JWT: JWTConfig{
-			Secret:        getEnv("JWT_SECRET", "default-secret-key"),
-			AccessExpiry:  accessExpiry,
-			RefreshExpiry: refreshExpiry,
+			Secret:             getEnv("JWT_SECRET", ""),
+			AccessExpiry:       accessExpiry,
+			RefreshExpiry:      refreshExpiry,
+			KeysDir:            getEnv("JWT_KEYS_DIR", ""),
+			ActiveKeyID:        getEnv("JWT_ACTIVE_KEY_ID", ""),
+			RevocationCacheTTL: revocationCacheTTL,
 		},
 		Server: ServerConfig{
 			Port: getEnv("SERVER_PORT", "8080"),
@@ -67,7 +236,112 @@ func Load() (*Config, error) {
 			User:     getEnv("SMTP_USER", ""),
 			Password: getEnv("SMTP_PASSWORD", ""),
 		},
-	}, nil
+		Mail: MailConfig{
+			Driver:    getEnv("MAIL_DRIVER", "log"),
+			From:      getEnv("MAIL_FROM", "no-reply@sensory-navigator.local"),
+			OutboxDir: getEnv("MAIL_OUTBOX_DIR", ""),
+			AppURL:    getEnv("APP_URL", "http://localhost:1420"),
+		},
+		Auth: AuthConfig{
+			EmailVerificationExpiry:    verificationExpiry,
+			EmailChangeExpiry:          emailChangeExpiry,
+			EmailChangeUndoExpiry:      emailChangeUndoExpiry,
+			AccountDeletionGracePeriod: deletionGracePeriod,
+			MagicLinkExpiry:            magicLinkExpiry,
+			MagicLinkEmailLimit:        magicLinkEmailLimit,
+			MagicLinkIPLimit:           magicLinkIPLimit,
+			TwoFactorChallengeExpiry:   twoFactorChallengeExpiry,
+			TOTPIssuer:                 getEnv("TOTP_ISSUER", "Sensory Navigator"),
+			RequireVerifiedEmail:       requireVerifiedEmail,
+			MailRequestEmailLimit:      mailRequestEmailLimit,
+			MailRequestIPLimit:         mailRequestIPLimit,
+			MailRequestWindow:          mailRequestWindow,
+			ReauthenticationExpiry:     reauthenticationExpiry,
+			AdminEmails:                splitList(getEnv("ADMIN_EMAILS", "")),
+		},
+		Login: LoginThrottleConfig{
+			Store:        getEnv("LOGIN_THROTTLE_STORE", "memory"),
+			DelayAfter:   loginDelayAfter,
+			BaseDelay:    loginBaseDelay,
+			MaxDelay:     loginMaxDelay,
+			LockAfter:    loginLockAfter,
+			LockDuration: loginLockDuration,
+			Window:       loginWindow,
+			IPMultiplier: loginIPMultiplier,
+		},
+		OIDC: OIDCConfig{
+			RedirectBaseURL: getEnv("OIDC_REDIRECT_BASE_URL", "http://localhost:8080"),
+			StateExpiry:     oidcStateExpiry,
+			Providers:       loadOIDCProviders(getEnv("OIDC_PROVIDERS", "")),
+		},
+		Password: PasswordPolicyConfig{
+			MinLength:            passwordMinLength,
+			MaxLength:            passwordMaxLength,
+			MinEntropy:           passwordMinEntropy,
+			BreachedPasswordsDir: getEnv("PASSWORD_BREACHED_DIR", ""),
+			BreachedMinCount:     passwordBreachedMinCount,
+		},
+		Places: PlacesConfig{
+			GeoBackend: getEnv("PLACES_GEO_BACKEND", "sql"),
+		},
+		Hash: PasswordHashConfig{
+			Algorithm:     getEnv("PASSWORD_HASH_ALGORITHM", "argon2id"),
+			BcryptCost:    bcryptCost,
+			Argon2Memory:  uint32(argon2Memory),
+			Argon2Time:    uint32(argon2Time),
+			Argon2Threads: uint8(argon2Threads),
+		},
+	}
+
+	if err := cfg.JWT.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// validate refuses secrets that could be guessed. The secret is needed even
+// with asymmetric keys, as it signs the tokens sent in emails.
+func (c *JWTConfig) validate() error {
+	if c.Secret == "" {
+		return errors.New("JWT_SECRET must be set")
+	}
+	if placeholderJWTSecrets[c.Secret] {
+		return errors.New("JWT_SECRET must not be the example value")
+	}
+	if len(c.Secret) < minJWTSecretLength {
+		return errors.New("JWT_SECRET must be at least " + strconv.Itoa(minJWTSecretLength) + " characters long")
+	}
+	return nil
+}
+
+func loadOIDCProviders(names string) map[string]OIDCProviderConfig {
+	providers := make(map[string]OIDCProviderConfig)
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(strings.ToLower(name))
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers[name] = OIDCProviderConfig{
+			Issuer:       getEnv(prefix+"ISSUER", ""),
+			ClientID:     getEnv(prefix+"CLIENT_ID", ""),
+			ClientSecret: getEnv(prefix+"CLIENT_SECRET", ""),
+			Scopes:       strings.Fields(getEnv(prefix+"SCOPES", "openid email profile")),
+		}
+	}
+	return providers
+}
+
+// splitList parses a comma-separated value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 func getEnv(key, defaultValue string) string {
@@ -76,4 +350,3 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
-
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJWTConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		secret  string
+		wantErr bool
+	}{
+		{name: "empty", secret: "", wantErr: true},
+		{name: "old default", secret: "default-secret-key", wantErr: true},
+		{name: "example value", secret: "your-super-secret-key-change-in-production", wantErr: true},
+		{name: "too short", secret: strings.Repeat("a", minJWTSecretLength-1), wantErr: true},
+		{name: "minimum length", secret: strings.Repeat("a", minJWTSecretLength)},
+		{name: "generated", secret: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &JWTConfig{Secret: tt.secret}
+			if err := cfg.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"admin@example.com", []string{"admin@example.com"}},
+		{" a@example.com , b@example.com ", []string{"a@example.com", "b@example.com"}},
+		{"a@example.com,,", []string{"a@example.com"}},
+	}
+
+	for _, tt := range tests {
+		got := splitList(tt.value)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") || len(got) != len(tt.want) {
+			t.Errorf("splitList(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
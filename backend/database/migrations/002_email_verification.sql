@@ -0,0 +1,5 @@
+-- Sensory Navigator Database Schema
+-- Migration 002: Email verification
+
+-- NULL means the user has not confirmed their email address yet
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMP WITH TIME ZONE;
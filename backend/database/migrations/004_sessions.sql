@@ -0,0 +1,8 @@
+-- Sensory Navigator Database Schema
+-- Migration 004: Session metadata on refresh tokens
+
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS user_agent TEXT;
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS ip_address VARCHAR(45);
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE refresh_tokens SET last_used_at = created_at WHERE last_used_at IS NULL;
@@ -0,0 +1,34 @@
+-- Sensory Navigator Database Schema
+-- Migration 005: Store refresh and password reset tokens as SHA-256 digests
+
+BEGIN;
+
+-- Existing rows hold plaintext tokens. Overwrite them with a value no digest
+-- can match and mark them unusable, so every session has to log in again
+-- and outstanding reset links stop working. Each table is converted only
+-- while it still has the plaintext column, so the migration can be re-run.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'refresh_tokens' AND column_name = 'token') THEN
+        ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+
+        UPDATE refresh_tokens
+        SET token_hash = 'invalidated:' || id,
+            revoked = TRUE,
+            revoked_at = COALESCE(revoked_at, CURRENT_TIMESTAMP);
+    END IF;
+
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_name = 'password_reset_tokens' AND column_name = 'token') THEN
+        ALTER TABLE password_reset_tokens RENAME COLUMN token TO token_hash;
+
+        UPDATE password_reset_tokens
+        SET token_hash = 'invalidated:' || id,
+            used = TRUE;
+    END IF;
+END $$;
+
+ALTER INDEX IF EXISTS idx_refresh_tokens_token RENAME TO idx_refresh_tokens_token_hash;
+
+COMMIT;
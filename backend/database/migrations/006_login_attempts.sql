@@ -0,0 +1,9 @@
+-- Sensory Navigator Database Schema
+-- Migration 006: Failed login counters for brute-force protection
+
+-- key is "email:<address>" or "ip:<address>"
+CREATE TABLE IF NOT EXISTS login_attempts (
+    key VARCHAR(320) PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
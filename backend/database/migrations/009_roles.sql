@@ -0,0 +1,7 @@
+-- Sensory Navigator Database Schema
+-- Migration 009: User roles
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'moderator', 'admin'));
+
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role) WHERE role <> 'user';
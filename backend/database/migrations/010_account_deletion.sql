@@ -0,0 +1,13 @@
+-- Sensory Navigator Database Schema
+-- Migration 010: Account deletion with a grace period
+
+-- Accounts are purged once deletion_scheduled_at has passed; until then
+-- the owner can restore them by signing in
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS keep_reviews_on_deletion BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_users_deletion_scheduled_at ON users(deletion_scheduled_at)
+    WHERE deletion_scheduled_at IS NOT NULL;
+
+-- Reviews kept by a deleted user remain as anonymous content
+ALTER TABLE reviews ALTER COLUMN user_id DROP NOT NULL;
@@ -0,0 +1,14 @@
+-- Sensory Navigator Database Schema
+-- Migration 012: Immediate access token revocation
+
+-- Access tokens carry the generation they were issued under; bumping it
+-- invalidates every access token of the user at once
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_generation BIGINT NOT NULL DEFAULT 0;
+
+-- Individual access tokens revoked before their expiry, e.g. on logout
+CREATE TABLE IF NOT EXISTS revoked_access_tokens (
+    jti VARCHAR(64) PRIMARY KEY,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_revoked_access_tokens_expires_at ON revoked_access_tokens(expires_at);
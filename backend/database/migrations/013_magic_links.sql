@@ -0,0 +1,18 @@
+-- Sensory Navigator Database Schema
+-- Migration 013: Passwordless login links
+
+-- Single-use login links sent by email. device_hash, when set, is the
+-- digest of a secret held by the client that asked for the link; the link
+-- then only works together with that secret.
+CREATE TABLE IF NOT EXISTS magic_link_tokens (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    device_hash VARCHAR(64),
+    ip_address VARCHAR(45),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_magic_link_tokens_user_id ON magic_link_tokens(user_id);
@@ -0,0 +1,51 @@
+-- Sensory Navigator Database Schema
+-- Migration 014: Append-only audit log replacing security_events
+
+BEGIN;
+
+-- actor_id and target_id have no foreign keys, so the log outlives
+-- deleted accounts
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id INTEGER,
+    target_id INTEGER,
+    event_type VARCHAR(100) NOT NULL,
+    ip_address VARCHAR(45),
+    user_agent TEXT,
+    details JSONB,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_target_id ON audit_events(target_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_id ON audit_events(actor_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_type ON audit_events(event_type, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_created_at ON audit_events(created_at DESC);
+
+-- Recorded events can neither be changed nor removed
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+-- Carry over the security events recorded so far. The old table is gone
+-- once this ran, so the migration can be re-run.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.tables
+               WHERE table_name = 'security_events') THEN
+        INSERT INTO audit_events (actor_id, target_id, event_type, details, created_at)
+        SELECT user_id, user_id, event_type, details, created_at
+        FROM security_events
+        ORDER BY id;
+
+        DROP TABLE security_events;
+    END IF;
+END $$;
+
+COMMIT;
@@ -0,0 +1,5 @@
+-- Sensory Navigator Database Schema
+-- Migration 015: Index place coordinates for nearby search
+
+-- Serves the bounding-box prefilter of the nearby search
+CREATE INDEX IF NOT EXISTS idx_places_location ON places(latitude, longitude);
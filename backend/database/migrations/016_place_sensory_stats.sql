@@ -0,0 +1,104 @@
+-- Sensory Navigator Database Schema
+-- Migration 016: Per-place sensory rating statistics
+
+BEGIN;
+
+-- One row per place and rated dimension. Ratings are whole numbers, so
+-- keeping their count, sum and sum of squares lets the mean and variance
+-- be updated exactly as reviews come and go. histogram[n] counts rating n.
+CREATE TABLE IF NOT EXISTS place_sensory_stats (
+    place_id INTEGER NOT NULL REFERENCES places(id) ON DELETE CASCADE,
+    dimension VARCHAR(20) NOT NULL,
+    rating_count INTEGER NOT NULL DEFAULT 0,
+    rating_sum BIGINT NOT NULL DEFAULT 0,
+    rating_sum_sq BIGINT NOT NULL DEFAULT 0,
+    histogram INTEGER[] NOT NULL DEFAULT '{0,0,0,0,0}',
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (place_id, dimension)
+);
+
+-- Adds (delta = 1) or removes (delta = -1) one rating. Removals never
+-- create rows: when a place is deleted its reviews go after it, and its
+-- statistics are already gone.
+CREATE OR REPLACE FUNCTION apply_place_sensory_rating(
+    p_place_id INTEGER, p_dimension TEXT, p_rating INTEGER, p_delta INTEGER
+) RETURNS VOID AS $$
+BEGIN
+    IF p_rating IS NULL THEN
+        RETURN;
+    END IF;
+
+    IF p_delta > 0 THEN
+        INSERT INTO place_sensory_stats (place_id, dimension)
+        VALUES (p_place_id, p_dimension)
+        ON CONFLICT (place_id, dimension) DO NOTHING;
+    END IF;
+
+    UPDATE place_sensory_stats
+    SET rating_count = rating_count + p_delta,
+        rating_sum = rating_sum + p_delta * p_rating,
+        rating_sum_sq = rating_sum_sq + p_delta * p_rating * p_rating,
+        histogram[p_rating] = histogram[p_rating] + p_delta,
+        updated_at = CURRENT_TIMESTAMP
+    WHERE place_id = p_place_id AND dimension = p_dimension;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION update_place_sensory_stats() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        PERFORM apply_place_sensory_rating(OLD.place_id, 'sensory', OLD.sensory_rating, -1);
+        PERFORM apply_place_sensory_rating(OLD.place_id, 'lighting', OLD.lighting_rating, -1);
+        PERFORM apply_place_sensory_rating(OLD.place_id, 'sound_level', OLD.sound_level_rating, -1);
+        PERFORM apply_place_sensory_rating(OLD.place_id, 'crowding', OLD.crowding_rating, -1);
+        PERFORM apply_place_sensory_rating(OLD.place_id, 'accessibility', OLD.accessibility_rating, -1);
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        PERFORM apply_place_sensory_rating(NEW.place_id, 'sensory', NEW.sensory_rating, 1);
+        PERFORM apply_place_sensory_rating(NEW.place_id, 'lighting', NEW.lighting_rating, 1);
+        PERFORM apply_place_sensory_rating(NEW.place_id, 'sound_level', NEW.sound_level_rating, 1);
+        PERFORM apply_place_sensory_rating(NEW.place_id, 'crowding', NEW.crowding_rating, 1);
+        PERFORM apply_place_sensory_rating(NEW.place_id, 'accessibility', NEW.accessibility_rating, 1);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS update_place_sensory_stats ON reviews;
+CREATE TRIGGER update_place_sensory_stats
+    AFTER INSERT OR DELETE OR UPDATE OF place_id, sensory_rating, lighting_rating,
+        sound_level_rating, crowding_rating, accessibility_rating ON reviews
+    FOR EACH ROW EXECUTE FUNCTION update_place_sensory_stats();
+
+-- Rebuilds the statistics of one place, or of every place when
+-- p_place_id is NULL, from its reviews
+CREATE OR REPLACE FUNCTION recompute_place_sensory_stats(p_place_id INTEGER) RETURNS VOID AS $$
+BEGIN
+    DELETE FROM place_sensory_stats WHERE p_place_id IS NULL OR place_id = p_place_id;
+
+    INSERT INTO place_sensory_stats (place_id, dimension, rating_count, rating_sum, rating_sum_sq, histogram)
+    SELECT r.place_id, d.dimension, COUNT(*), SUM(d.rating), SUM(d.rating * d.rating),
+        ARRAY[
+            COUNT(*) FILTER (WHERE d.rating = 1),
+            COUNT(*) FILTER (WHERE d.rating = 2),
+            COUNT(*) FILTER (WHERE d.rating = 3),
+            COUNT(*) FILTER (WHERE d.rating = 4),
+            COUNT(*) FILTER (WHERE d.rating = 5)
+        ]::INTEGER[]
+    FROM reviews r
+    CROSS JOIN LATERAL (VALUES
+        ('sensory', r.sensory_rating),
+        ('lighting', r.lighting_rating),
+        ('sound_level', r.sound_level_rating),
+        ('crowding', r.crowding_rating),
+        ('accessibility', r.accessibility_rating)
+    ) AS d(dimension, rating)
+    WHERE d.rating IS NOT NULL AND (p_place_id IS NULL OR r.place_id = p_place_id)
+    GROUP BY r.place_id, d.dimension;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Build the statistics of the reviews written so far
+SELECT recompute_place_sensory_stats(NULL);
+
+COMMIT;
@@ -0,0 +1,14 @@
+-- Sensory Navigator Database Schema
+-- Migration 017: Personal sensory tolerance profiles
+
+-- One row per user and dimension they care about. tolerance is the most
+-- intense rating the user can bear, or for accessibility the least
+-- accessible one; weight is how much the dimension counts in fit scores.
+CREATE TABLE IF NOT EXISTS user_sensory_preferences (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    dimension VARCHAR(20) NOT NULL,
+    tolerance INTEGER NOT NULL CHECK (tolerance >= 1 AND tolerance <= 5),
+    weight INTEGER NOT NULL CHECK (weight >= 1 AND weight <= 5),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, dimension)
+);
@@ -0,0 +1,95 @@
+-- Sensory Navigator Database Schema
+-- Migration 018: Review aggregates and indexes for place search
+
+BEGIN;
+
+-- Kept on places so search can filter and sort by them through indexes.
+-- rating is the mean overall_rating, NULL until a review sets one.
+ALTER TABLE places ADD COLUMN IF NOT EXISTS review_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE places ADD COLUMN IF NOT EXISTS rating_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE places ADD COLUMN IF NOT EXISTS rating_sum NUMERIC NOT NULL DEFAULT 0;
+ALTER TABLE places ADD COLUMN IF NOT EXISTS rating DOUBLE PRECISION
+    GENERATED ALWAYS AS (CASE WHEN rating_count > 0 THEN (rating_sum / rating_count)::float8 END) STORED;
+
+-- Per-dimension mean for the rating range filters
+ALTER TABLE place_sensory_stats ADD COLUMN IF NOT EXISTS rating_mean DOUBLE PRECISION
+    GENERATED ALWAYS AS (CASE WHEN rating_count > 0 THEN rating_sum::float8 / rating_count END) STORED;
+
+CREATE OR REPLACE FUNCTION update_place_review_counts() RETURNS TRIGGER AS $$
+BEGIN
+    -- Updates of a deleted place's reviews find no place and do nothing
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        UPDATE places
+        SET review_count = review_count - 1,
+            rating_count = rating_count - (OLD.overall_rating IS NOT NULL)::int,
+            rating_sum = rating_sum - COALESCE(OLD.overall_rating, 0)
+        WHERE id = OLD.place_id;
+    END IF;
+    IF TG_OP IN ('INSERT', 'UPDATE') THEN
+        UPDATE places
+        SET review_count = review_count + 1,
+            rating_count = rating_count + (NEW.overall_rating IS NOT NULL)::int,
+            rating_sum = rating_sum + COALESCE(NEW.overall_rating, 0)
+        WHERE id = NEW.place_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS update_place_review_counts ON reviews;
+CREATE TRIGGER update_place_review_counts
+    AFTER INSERT OR DELETE OR UPDATE OF place_id, overall_rating ON reviews
+    FOR EACH ROW EXECUTE FUNCTION update_place_review_counts();
+
+-- The admin recompute now rebuilds the review aggregates as well
+CREATE OR REPLACE FUNCTION recompute_place_sensory_stats(p_place_id INTEGER) RETURNS VOID AS $$
+BEGIN
+    DELETE FROM place_sensory_stats WHERE p_place_id IS NULL OR place_id = p_place_id;
+
+    INSERT INTO place_sensory_stats (place_id, dimension, rating_count, rating_sum, rating_sum_sq, histogram)
+    SELECT r.place_id, d.dimension, COUNT(*), SUM(d.rating), SUM(d.rating * d.rating),
+        ARRAY[
+            COUNT(*) FILTER (WHERE d.rating = 1),
+            COUNT(*) FILTER (WHERE d.rating = 2),
+            COUNT(*) FILTER (WHERE d.rating = 3),
+            COUNT(*) FILTER (WHERE d.rating = 4),
+            COUNT(*) FILTER (WHERE d.rating = 5)
+        ]::INTEGER[]
+    FROM reviews r
+    CROSS JOIN LATERAL (VALUES
+        ('sensory', r.sensory_rating),
+        ('lighting', r.lighting_rating),
+        ('sound_level', r.sound_level_rating),
+        ('crowding', r.crowding_rating),
+        ('accessibility', r.accessibility_rating)
+    ) AS d(dimension, rating)
+    WHERE d.rating IS NOT NULL AND (p_place_id IS NULL OR r.place_id = p_place_id)
+    GROUP BY r.place_id, d.dimension;
+
+    UPDATE places p
+    SET review_count = COALESCE(agg.review_count, 0),
+        rating_count = COALESCE(agg.rating_count, 0),
+        rating_sum = COALESCE(agg.rating_sum, 0)
+    FROM places target
+    LEFT JOIN (
+        SELECT place_id, COUNT(*) AS review_count, COUNT(overall_rating) AS rating_count,
+            SUM(overall_rating) AS rating_sum
+        FROM reviews
+        GROUP BY place_id
+    ) agg ON agg.place_id = target.id
+    WHERE p.id = target.id AND (p_place_id IS NULL OR target.id = p_place_id);
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT recompute_place_sensory_stats(NULL);
+
+-- Sort orders of the search, each with id as the tie-breaker the cursor
+-- relies on
+CREATE INDEX IF NOT EXISTS idx_places_rating ON places ((COALESCE(rating, 0)) DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_places_review_count ON places(review_count DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_places_category ON places(category, id);
+
+-- Serves the rating range filters from the statistics side
+CREATE INDEX IF NOT EXISTS idx_place_sensory_stats_mean ON place_sensory_stats(dimension, rating_mean, place_id);
+
+COMMIT;
@@ -0,0 +1,62 @@
+-- Sensory Navigator Database Schema
+-- Migration 019: Full-text search over places and reviews
+
+BEGIN;
+
+-- Trigram matching for place name autocomplete
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE places ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;
+
+-- Text is indexed with both the Russian and the English configuration so
+-- either language matches with its own stemming. Place names weigh more
+-- than addresses.
+CREATE OR REPLACE FUNCTION places_search_vector(p_name TEXT, p_address TEXT) RETURNS TSVECTOR AS $$
+    SELECT setweight(to_tsvector('russian', COALESCE(p_name, '')), 'A') ||
+        setweight(to_tsvector('english', COALESCE(p_name, '')), 'A') ||
+        setweight(to_tsvector('russian', COALESCE(p_address, '')), 'B') ||
+        setweight(to_tsvector('english', COALESCE(p_address, '')), 'B');
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION reviews_search_vector(p_text TEXT) RETURNS TSVECTOR AS $$
+    SELECT to_tsvector('russian', COALESCE(p_text, '')) ||
+        to_tsvector('english', COALESCE(p_text, ''));
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE OR REPLACE FUNCTION update_places_search_vector() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector := places_search_vector(NEW.name, NEW.address);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION update_reviews_search_vector() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.search_vector := reviews_search_vector(NEW.text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS update_places_search_vector ON places;
+CREATE TRIGGER update_places_search_vector
+    BEFORE INSERT OR UPDATE OF name, address ON places
+    FOR EACH ROW EXECUTE FUNCTION update_places_search_vector();
+
+DROP TRIGGER IF EXISTS update_reviews_search_vector ON reviews;
+CREATE TRIGGER update_reviews_search_vector
+    BEFORE INSERT OR UPDATE OF text ON reviews
+    FOR EACH ROW EXECUTE FUNCTION update_reviews_search_vector();
+
+-- Fill in the vectors of existing rows without touching review
+-- timestamps
+UPDATE places SET search_vector = places_search_vector(name, address);
+ALTER TABLE reviews DISABLE TRIGGER update_reviews_updated_at;
+UPDATE reviews SET search_vector = reviews_search_vector(text);
+ALTER TABLE reviews ENABLE TRIGGER update_reviews_updated_at;
+
+CREATE INDEX IF NOT EXISTS idx_places_search_vector ON places USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_reviews_search_vector ON reviews USING GIN (search_vector);
+CREATE INDEX IF NOT EXISTS idx_places_name_trgm ON places USING GIN (name gin_trgm_ops);
+
+COMMIT;
@@ -0,0 +1,14 @@
+-- Sensory Navigator Database Schema
+-- Migration 020: Time of visit on reviews
+
+-- visited_at is the local time at the place, hence without a time zone.
+-- Reviewers who do not remember the date give only the day of the week
+-- and hour, so visit_hour_of_week is stored on its own: 0 is Monday
+-- 00:00-00:59, 167 is Sunday 23:00-23:59.
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS visited_at TIMESTAMP;
+ALTER TABLE reviews ADD COLUMN IF NOT EXISTS visit_hour_of_week SMALLINT
+    CHECK (visit_hour_of_week >= 0 AND visit_hour_of_week <= 167);
+
+-- Serves the per-place aggregates by time of visit
+CREATE INDEX IF NOT EXISTS idx_reviews_place_visit ON reviews(place_id, visit_hour_of_week)
+    WHERE visit_hour_of_week IS NOT NULL;
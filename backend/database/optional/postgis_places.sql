@@ -0,0 +1,12 @@
+-- Sensory Navigator Database Schema
+-- Optional: PostGIS index for nearby place search
+--
+-- Apply after the migrations and set PLACES_GEO_BACKEND=postgis. Without
+-- it nearby search uses a bounding box and haversine in plain SQL.
+
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+-- Must match placeGeography in repository/place_geo.go
+CREATE INDEX IF NOT EXISTS idx_places_geography ON places
+    USING GIST ((ST_SetSRID(ST_MakePoint(longitude::float8, latitude::float8), 4326)::geography))
+    WHERE latitude IS NOT NULL AND longitude IS NOT NULL;
@@ -5,8 +5,9 @@ go 1.21
 require (
 	github.com/gin-gonic/gin v1.9.1
 	github.com/golang-jwt/jwt/v5 v5.2.0
+	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
 	golang.org/x/crypto v0.17.0
-	github.com/joho/godotenv v1.5.1
 )
 
+require golang.org/x/sys v0.15.0 // indirect
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"sensory-navigator/models"
+	"sensory-navigator/services"
+)
+
+type AccountHandler struct {
+	authService   *services.AuthService
+	exportService *services.ExportService
+}
+
+func NewAccountHandler(authService *services.AuthService, exportService *services.ExportService) *AccountHandler {
+	return &AccountHandler{
+		authService:   authService,
+		exportService: exportService,
+	}
+}
+
+// PUT /api/users/me/password
+func (h *AccountHandler) ChangePassword(c *gin.Context) {
+	userID := c.GetInt64("userID")
+
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	accessToken, err := h.authService.ChangePassword(userID, c.GetString("sessionID"), &req, clientInfo(c))
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+
+	// Earlier access tokens were revoked, the client continues with this one
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "password changed, other sessions have been signed out",
+		"access_token": accessToken,
+	})
+}
+
+// POST /api/users/me/email
+func (h *AccountHandler) RequestEmailChange(c *gin.Context) {
+	userID := c.GetInt64("userID")
+
+	var req models.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.authService.RequestEmailChange(userID, &req, clientInfo(c)); err != nil {
+		writeAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "a confirmation link has been sent to the new address"})
+}
+
+// POST /api/auth/confirm-email-change
+func (h *AccountHandler) ConfirmEmailChange(c *gin.Context) {
+	var req models.EmailChangeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.authService.ConfirmEmailChange(req.Token, clientInfo(c))
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user.ToResponse())
+}
+
+// POST /api/auth/undo-email-change
+func (h *AccountHandler) UndoEmailChange(c *gin.Context) {
+	var req models.EmailChangeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.authService.UndoEmailChange(req.Token, clientInfo(c)); err != nil {
+		writeAccountError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "previous email restored, all sessions have been signed out"})
+}
+
+// GET /api/users/me/export
+func (h *AccountHandler) Export(c *gin.Context) {
+	userID := c.GetInt64("userID")
+
+	export, err := h.exportService.Export(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export data"})
+		return
+	}
+
+	if c.DefaultQuery("format", "json") != "zip" {
+		c.Header("Content-Disposition", `attachment; filename="sensory-navigator-export.json"`)
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="sensory-navigator-export.zip"`)
+	if err := h.exportService.WriteZip(c.Writer, export); err != nil {
+		// Headers are already sent, the client sees a truncated archive
+		log.Printf("Failed to write data export for user %d: %v", userID, err)
+	}
+}
+
+// DELETE /api/users/me
+func (h *AccountHandler) DeleteAccount(c *gin.Context) {
+	userID := c.GetInt64("userID")
+
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.authService.DeleteAccount(userID, &req, clientInfo(c))
+	if err != nil {
+		writeAccountError(c, err)
+		return
+	}
+
+	if err := h.authService.SendAccountDeletionEmail(user); err != nil {
+		log.Printf("Failed to send account deletion email to %s: %v", user.Email, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":               "account scheduled for deletion, sign in before the date to restore it",
+		"deletion_scheduled_at": user.ToResponse().DeletionScheduledAt,
+	})
+}
+
+// POST /api/auth/restore-account
+func (h *AccountHandler) RestoreAccount(c *gin.Context) {
+	var req models.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.authService.RestoreAccount(&req, clientInfo(c))
+	writeLoginResult(c, result, err)
+}
+
+func writeAccountError(c *gin.Context, err error) {
+	if writeThrottleError(c, err) || writePasswordPolicyError(c, err) {
+		return
+	}
+	switch err {
+	case services.ErrInvalidCredentials:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "current password is incorrect"})
+	case services.ErrEmailTaken:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case services.ErrEmailUnchanged, services.ErrInvalidEmailChangeToken:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update account"})
+	}
+}
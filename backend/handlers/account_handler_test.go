@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"sensory-navigator/services"
+)
+
+func TestWriteAccountError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		err            error
+		wantStatus     int
+		wantRetryAfter string
+	}{
+		{name: "wrong password", err: services.ErrInvalidCredentials, wantStatus: http.StatusUnauthorized},
+		{name: "email taken", err: services.ErrEmailTaken, wantStatus: http.StatusConflict},
+		{name: "same email", err: services.ErrEmailUnchanged, wantStatus: http.StatusBadRequest},
+		{name: "bad link", err: services.ErrInvalidEmailChangeToken, wantStatus: http.StatusBadRequest},
+		{name: "delayed", err: &services.ThrottleError{RetryAfter: 1500 * time.Millisecond}, wantStatus: http.StatusTooManyRequests, wantRetryAfter: "2"},
+		{name: "locked", err: &services.ThrottleError{Locked: true, RetryAfter: time.Minute}, wantStatus: http.StatusLocked, wantRetryAfter: "60"},
+		{name: "unexpected", err: errors.New("connection reset"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			writeAccountError(c, tt.err)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.wantRetryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.wantRetryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sensory-navigator/models"
+	"sensory-navigator/repository"
+	"sensory-navigator/services"
+)
+
+type AdminHandler struct {
+	userRepo    *repository.UserRepository
+	authService *services.AuthService
+	auditor     services.Auditor
+}
+
+func NewAdminHandler(userRepo *repository.UserRepository, authService *services.AuthService, auditor services.Auditor) *AdminHandler {
+	return &AdminHandler{
+		userRepo:    userRepo,
+		authService: authService,
+		auditor:     auditor,
+	}
+}
+
+// GET /api/admin/users
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	role := c.Query("role")
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	users, err := h.userRepo.FindAll(role, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch users"})
+		return
+	}
+
+	response := make([]models.UserResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, user.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"users":  response,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GET /api/admin/users/:id
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	user, err := h.userRepo.FindByID(userID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user.ToResponse())
+}
+
+// PUT /api/admin/users/:id/role
+func (h *AdminHandler) UpdateRole(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	var req models.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Keeps the last admin from locking everyone out by accident
+	if userID == c.GetInt64("userID") && req.Role != models.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "you cannot remove your own admin role"})
+		return
+	}
+
+	previous, err := h.userRepo.FindByID(userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update role"})
+		return
+	}
+
+	user, err := h.userRepo.UpdateRole(userID, req.Role)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update role"})
+		return
+	}
+
+	// Tokens carry the role, make the change apply right away
+	if err := h.authService.RevokeAccessTokens(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update role"})
+		return
+	}
+
+	h.audit(c, models.EventRoleChanged, userID, map[string]interface{}{
+		"old_role": previous.Role,
+		"new_role": user.Role,
+	})
+
+	c.JSON(http.StatusOK, user.ToResponse())
+}
+
+// DELETE /api/admin/users/:id/sessions
+func (h *AdminHandler) RevokeSessions(c *gin.Context) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user ID"})
+		return
+	}
+
+	err = h.authService.LogoutAll(userID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+
+	h.audit(c, models.EventSessionsRevoked, userID, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "all sessions revoked"})
+}
+
+// audit records an action of the signed-in admin on another account. The
+// action has already been taken, so a failure is only logged.
+func (h *AdminHandler) audit(c *gin.Context, eventType string, targetID int64, details map[string]interface{}) {
+	adminID := c.GetInt64("userID")
+	if err := h.auditor.Record(services.NewAuditEvent(eventType, adminID, targetID, clientInfo(c), details)); err != nil {
+		log.Printf("Failed to record %s by admin %d: %v", eventType, adminID, err)
+	}
+}
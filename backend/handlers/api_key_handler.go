@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sensory-navigator/models"
+	"sensory-navigator/services"
+)
+
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyHandler(apiKeyService *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{apiKeyService: apiKeyService}
+}
+
+// GET /api/users/me/api-keys
+func (h *APIKeyHandler) List(c *gin.Context) {
+	userID := c.GetInt64("userID")
+
+	keys, err := h.apiKeyService.List(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// POST /api/users/me/api-keys
+func (h *APIKeyHandler) Create(c *gin.Context) {
+	userID := c.GetInt64("userID")
+
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, err := h.apiKeyService.Create(userID, &req)
+	if err == services.ErrTooManyAPIKeys {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create API key"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// DELETE /api/users/me/api-keys/:id
+func (h *APIKeyHandler) Revoke(c *gin.Context) {
+	userID := c.GetInt64("userID")
+
+	keyID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid API key ID"})
+		return
+	}
+
+	err = h.apiKeyService.Revoke(userID, keyID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke API key"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+// POST /api/oauth/token
+//
+// OAuth2 client credentials grant (RFC 6749, section 4.4). Credentials are
+// accepted through HTTP Basic authentication or as form parameters; errors
+// use the error codes defined by the RFC.
+func (h *APIKeyHandler) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	if c.PostForm("grant_type") != "client_credentials" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_grant_type"})
+		return
+	}
+
+	clientID, clientSecret, ok := c.Request.BasicAuth()
+	if !ok {
+		clientID, clientSecret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+
+	token, err := h.apiKeyService.IssueClientToken(clientID, clientSecret, c.PostForm("scope"), c.ClientIP())
+	switch err {
+	case nil:
+		c.JSON(http.StatusOK, token)
+	case services.ErrInvalidAPIKey:
+		c.Header("WWW-Authenticate", `Basic realm="api"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+	case services.ErrInvalidScope:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_scope"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+	}
+}
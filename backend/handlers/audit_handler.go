@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"sensory-navigator/models"
+	"sensory-navigator/repository"
+)
+
+// maxAuditEventsLimit bounds the page size of audit event lists.
+const maxAuditEventsLimit = 100
+
+type AuditHandler struct {
+	auditRepo *repository.AuditEventRepository
+}
+
+func NewAuditHandler(auditRepo *repository.AuditEventRepository) *AuditHandler {
+	return &AuditHandler{auditRepo: auditRepo}
+}
+
+// GET /api/users/me/security-events
+func (h *AuditHandler) GetMySecurityEvents(c *gin.Context) {
+	limit, offset, ok := parsePage(c, 20, maxAuditEventsLimit)
+	if !ok {
+		return
+	}
+
+	events, total, err := h.auditRepo.Find(&models.AuditEventFilter{
+		TargetID: c.GetInt64("userID"),
+		Limit:    limit,
+		Offset:   offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch security events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GET /api/admin/audit-events
+func (h *AuditHandler) ListEvents(c *gin.Context) {
+	filter := &models.AuditEventFilter{
+		EventType: c.Query("event_type"),
+		IPAddress: c.Query("ip_address"),
+	}
+	var ok bool
+	if filter.Limit, filter.Offset, ok = parsePage(c, 50, maxAuditEventsLimit); !ok {
+		return
+	}
+
+	var err error
+	if value := c.Query("user_id"); value != "" {
+		if filter.TargetID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user_id"})
+			return
+		}
+	}
+	if value := c.Query("actor_id"); value != "" {
+		if filter.ActorID, err = strconv.ParseInt(value, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid actor_id"})
+			return
+		}
+	}
+	if value := c.Query("from"); value != "" {
+		if filter.From, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be an RFC 3339 time"})
+			return
+		}
+	}
+	if value := c.Query("to"); value != "" {
+		if filter.To, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be an RFC 3339 time"})
+			return
+		}
+	}
+
+	events, total, err := h.auditRepo.Find(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch audit events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"total":  total,
+		"limit":  filter.Limit,
+		"offset": filter.Offset,
+	})
+}
+
+// parseLimit reads the limit query parameter, which must be between 1 and
+// maxLimit. It answers with a bad request otherwise.
+func parseLimit(c *gin.Context, defaultLimit, maxLimit int) (int, bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if err != nil || limit <= 0 || limit > maxLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and " + strconv.Itoa(maxLimit)})
+		return 0, false
+	}
+	return limit, true
+}
+
+// parsePage reads the limit and offset query parameters of a paged list.
+// It answers with a bad request if either is out of range.
+func parsePage(c *gin.Context, defaultLimit, maxLimit int) (limit, offset int, ok bool) {
+	if limit, ok = parseLimit(c, defaultLimit, maxLimit); !ok {
+		return 0, 0, false
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return 0, 0, false
+	}
+	return limit, offset, true
+}
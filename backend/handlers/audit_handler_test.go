@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParsePage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantOK     bool
+		wantLimit  int
+		wantOffset int
+	}{
+		{name: "defaults", query: "", wantOK: true, wantLimit: 20},
+		{name: "explicit", query: "limit=5&offset=10", wantOK: true, wantLimit: 5, wantOffset: 10},
+		{name: "largest limit", query: "limit=100", wantOK: true, wantLimit: 100},
+		{name: "limit too large", query: "limit=101"},
+		{name: "zero limit", query: "limit=0"},
+		{name: "negative limit", query: "limit=-1"},
+		{name: "limit not a number", query: "limit=ten"},
+		{name: "negative offset", query: "offset=-1"},
+		{name: "offset not a number", query: "offset=first"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+
+			limit, offset, ok := parsePage(c, 20, maxAuditEventsLimit)
+
+			if ok != tt.wantOK {
+				t.Fatalf("parsePage() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				if w.Code != http.StatusBadRequest {
+					t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+				}
+				return
+			}
+			if limit != tt.wantLimit || offset != tt.wantOffset {
+				t.Errorf("parsePage() = (%d, %d), want (%d, %d)", limit, offset, tt.wantLimit, tt.wantOffset)
+			}
+		})
+	}
+}
@@ -1,7 +1,12 @@
 package handlers
 
 import (
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 
@@ -25,14 +30,25 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	user, err := h.authService.Register(&req)
-	if err != nil {
+	user, err := h.authService.Register(&req, clientInfo(c))
+	if writePasswordPolicyError(c, err) {
+		return
+	}
+	if err == services.ErrEmailTaken {
 		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create user"})
+		return
+	}
+
+	if err := h.authService.SendVerificationEmail(user); err != nil {
+		log.Printf("Failed to send verification email to %s: %v", user.Email, err)
+	}
 
 	// Generate tokens after registration
-	tokens, err := h.authService.GenerateTokenPair(user.ID)
+	tokens, err := h.authService.GenerateTokenPair(user.ID, clientInfo(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate tokens"})
 		return
@@ -52,16 +68,89 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	user, tokens, err := h.authService.Login(&req)
-	if err != nil {
+	result, err := h.authService.Login(&req, clientInfo(c))
+	writeLoginResult(c, result, err)
+}
+
+// POST /api/auth/login/2fa
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var req models.LoginTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.authService.LoginTwoFactor(req.ChallengeToken, req.Code, clientInfo(c))
+	writeLoginResult(c, result, err)
+}
+
+// writeLoginResult answers either with tokens or with a two-factor
+// challenge, and maps throttling to 429/423 with Retry-After.
+func writeLoginResult(c *gin.Context, result *services.LoginResult, err error) {
+	if writeThrottleError(c, err) {
+		return
+	}
+	switch err {
+	case nil:
+	case services.ErrInvalidCredentials, services.ErrInvalidTwoFactorCode, services.ErrInvalidLoginChallenge, services.ErrInvalidMagicLink:
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
+	case services.ErrAccountPendingDeletion:
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to login"})
+		return
+	}
+
+	if result.ChallengeToken != "" {
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge_token":     result.ChallengeToken,
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"user":   user.ToResponse(),
-		"tokens": tokens,
+		"user":   result.User.ToResponse(),
+		"tokens": result.Tokens,
+	})
+}
+
+// writeThrottleError answers with 429, or 423 for a locked account, if err
+// is a *services.ThrottleError and reports whether it did.
+func writeThrottleError(c *gin.Context, err error) bool {
+	var throttleErr *services.ThrottleError
+	if !errors.As(err, &throttleErr) {
+		return false
+	}
+
+	status := http.StatusTooManyRequests
+	if throttleErr.Locked {
+		status = http.StatusLocked
+	}
+	retryAfter := int(math.Ceil(throttleErr.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(status, gin.H{"error": err.Error(), "retry_after": retryAfter})
+	return true
+}
+
+// writePasswordPolicyError answers with 400 and the unmet requirements
+// keyed by request field if err is a *services.PasswordPolicyError, and
+// reports whether it did.
+func writePasswordPolicyError(c *gin.Context, err error) bool {
+	var policyErr *services.PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": err.Error(),
+		"fields": gin.H{
+			policyErr.Field: policyErr.Violations,
+		},
 	})
+	return true
 }
 
 // POST /api/auth/refresh
@@ -72,7 +161,7 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 		return
 	}
 
-	tokens, err := h.authService.RefreshTokens(req.RefreshToken)
+	tokens, err := h.authService.RefreshTokens(req.RefreshToken, clientInfo(c))
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
@@ -81,6 +170,36 @@ func (h *AuthHandler) Refresh(c *gin.Context) {
 	c.JSON(http.StatusOK, tokens)
 }
 
+// POST /api/auth/logout
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The access token is optional; when sent it is revoked as well
+	accessToken := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if err := h.authService.Logout(req.RefreshToken, accessToken, clientInfo(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+// POST /api/auth/logout-all
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userID := c.GetInt64("userID")
+
+	if err := h.authService.LogoutAll(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to logout"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "logged out from all devices"})
+}
+
 // POST /api/auth/forgot-password
 func (h *AuthHandler) ForgotPassword(c *gin.Context) {
 	var req models.ForgotPasswordRequest
@@ -89,8 +208,14 @@ func (h *AuthHandler) ForgotPassword(c *gin.Context) {
 		return
 	}
 
+	err := h.authService.ForgotPassword(req.Email, clientInfo(c))
+	if writeRateLimitError(c, err) {
+		return
+	}
 	// Always return success to prevent email enumeration
-	h.authService.ForgotPassword(req.Email)
+	if err != nil {
+		log.Printf("Failed to process password reset for %s: %v", req.Email, err)
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "If the email exists, a password reset link will be sent",
@@ -105,7 +230,10 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 		return
 	}
 
-	if err := h.authService.ResetPassword(req.Token, req.NewPassword); err != nil {
+	if err := h.authService.ResetPassword(req.Token, req.NewPassword, clientInfo(c)); err != nil {
+		if writePasswordPolicyError(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -115,3 +243,126 @@ func (h *AuthHandler) ResetPassword(c *gin.Context) {
 	})
 }
 
+// POST /api/auth/magic-link
+func (h *AuthHandler) RequestMagicLink(c *gin.Context) {
+	var req models.MagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	deviceToken, err := h.authService.RequestMagicLink(req.Email, req.BindDevice, clientInfo(c))
+	if writeRateLimitError(c, err) {
+		return
+	}
+	// Always return success to prevent email enumeration
+	if err != nil {
+		log.Printf("Failed to send login link to %s: %v", req.Email, err)
+	}
+
+	response := gin.H{"message": "If the email exists, a login link will be sent"}
+	if deviceToken != "" {
+		response["device_token"] = deviceToken
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// POST /api/auth/magic-link/verify
+func (h *AuthHandler) VerifyMagicLink(c *gin.Context) {
+	var req models.VerifyMagicLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.authService.VerifyMagicLink(req.Token, req.DeviceToken, clientInfo(c))
+	writeLoginResult(c, result, err)
+}
+
+// POST /api/auth/verify-email
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.authService.VerifyEmail(req.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, user.ToResponse())
+}
+
+// POST /api/auth/resend-verification
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req models.ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.authService.ResendVerification(req.Email, c.ClientIP())
+	if writeRateLimitError(c, err) {
+		return
+	}
+	// Always return success to prevent email enumeration
+	if err != nil {
+		log.Printf("Failed to resend verification email to %s: %v", req.Email, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If the email exists and is not verified, a confirmation link will be sent",
+	})
+}
+
+// GET /.well-known/jwks.json
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"keys": h.authService.JWKS()})
+}
+
+// POST /api/auth/reauthenticate
+func (h *AuthHandler) RequestReauthentication(c *gin.Context) {
+	var req models.ReauthenticationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.authService.RequestReauthentication(req.Email, clientInfo(c))
+	if writeRateLimitError(c, err) {
+		return
+	}
+	// Always return success to prevent email enumeration
+	if err != nil {
+		log.Printf("Failed to send reauthentication email to %s: %v", req.Email, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "If the account has no password, a confirmation link will be sent",
+	})
+}
+
+// clientInfo describes the device making the request.
+func clientInfo(c *gin.Context) *models.ClientInfo {
+	return &models.ClientInfo{
+		UserAgent: c.Request.UserAgent(),
+		IPAddress: c.ClientIP(),
+	}
+}
+
+// writeRateLimitError answers with 429 if err is a *services.RateLimitError
+// and reports whether it did.
+func writeRateLimitError(c *gin.Context, err error) bool {
+	var rateLimitErr *services.RateLimitError
+	if !errors.As(err, &rateLimitErr) {
+		return false
+	}
+
+	retryAfter := int(math.Ceil(rateLimitErr.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "retry_after": retryAfter})
+	return true
+}
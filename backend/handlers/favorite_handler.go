@@ -28,6 +28,10 @@ func (h *FavoriteHandler) AddFavorite(c *gin.Context) {
 	}
 
 	favorite, err := h.favoriteRepo.Add(userID, placeID)
+	if repository.IsForeignKeyViolation(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "place not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to add favorite"})
 		return
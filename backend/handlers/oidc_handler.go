@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sensory-navigator/services"
+)
+
+// oidcStateCookie keeps the state of a started login in the browser, so the
+// callback only completes logins that browser started.
+const (
+	oidcStateCookie     = "oidc_state"
+	oidcStateCookiePath = "/api/auth/oidc"
+)
+
+type OIDCHandler struct {
+	oidcService *services.OIDCService
+	appURL      string
+}
+
+func NewOIDCHandler(oidcService *services.OIDCService, appURL string) *OIDCHandler {
+	return &OIDCHandler{
+		oidcService: oidcService,
+		appURL:      appURL,
+	}
+}
+
+// GET /api/auth/oidc/:provider/start
+func (h *OIDCHandler) Start(c *gin.Context) {
+	redirectURL, state, err := h.oidcService.StartURL(c.Param("provider"))
+	if err == services.ErrUnknownOIDCProvider {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to start OIDC login: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider is unavailable"})
+		return
+	}
+
+	// Lax still sends the cookie on the provider's top-level redirect back
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     oidcStateCookiePath,
+		MaxAge:   int(h.oidcService.StateExpiry().Seconds()),
+		Secure:   h.oidcService.SecureCallback(),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	c.Redirect(http.StatusFound, redirectURL)
+}
+
+// GET /api/auth/oidc/:provider/callback
+//
+// The browser is sent back to the frontend with the result in the URL
+// fragment, which is never transmitted to servers.
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	// The state is used up either way
+	browserState, _ := c.Cookie(oidcStateCookie)
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oidcStateCookie,
+		Path:     oidcStateCookiePath,
+		MaxAge:   -1,
+		Secure:   h.oidcService.SecureCallback(),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	if providerError := c.Query("error"); providerError != "" {
+		h.redirectToApp(c, url.Values{"error": {providerError}})
+		return
+	}
+
+	result, err := h.oidcService.Callback(c.Param("provider"), c.Query("code"), c.Query("state"), browserState, clientInfo(c))
+	if err != nil {
+		switch err {
+		case services.ErrUnknownOIDCProvider, services.ErrInvalidOIDCState,
+			services.ErrOIDCEmailRequired, services.ErrOIDCAccountExists,
+			services.ErrOIDCLoginConflict, services.ErrAccountPendingDeletion:
+		default:
+			log.Printf("OIDC login failed: %v", err)
+			err = services.ErrInvalidOIDCState
+		}
+		h.redirectToApp(c, url.Values{"error": {err.Error()}})
+		return
+	}
+
+	if result.ChallengeToken != "" {
+		h.redirectToApp(c, url.Values{"challenge_token": {result.ChallengeToken}})
+		return
+	}
+
+	h.redirectToApp(c, url.Values{
+		"access_token":  {result.Tokens.AccessToken},
+		"refresh_token": {result.Tokens.RefreshToken},
+		"expires_in":    {strconv.FormatInt(result.Tokens.ExpiresIn, 10)},
+	})
+}
+
+func (h *OIDCHandler) redirectToApp(c *gin.Context, fragment url.Values) {
+	c.Redirect(http.StatusFound, h.appURL+"/auth/callback#"+fragment.Encode())
+}
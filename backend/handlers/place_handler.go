@@ -0,0 +1,596 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"sensory-navigator/models"
+	"sensory-navigator/repository"
+	"sensory-navigator/services"
+)
+
+// maxPlacesLimit bounds the page size of place listings and searches.
+const maxPlacesLimit = 100
+
+// maxNearbyRadiusM bounds nearby searches to a city-sized area.
+const maxNearbyRadiusM = 50000
+
+// Limits of the full-text search and autocomplete.
+const (
+	maxSearchQueryLength   = 200
+	searchSnippetsPerPlace = 3
+	minAutocompleteLength  = 2
+	maxAutocompleteLimit   = 20
+)
+
+type PlaceHandler struct {
+	placeRepo *repository.PlaceRepository
+	nearby    repository.NearbyPlaceFinder
+	statsRepo *repository.SensoryStatsRepository
+	prefRepo  *repository.SensoryPreferenceRepository
+}
+
+func NewPlaceHandler(placeRepo *repository.PlaceRepository, nearby repository.NearbyPlaceFinder, statsRepo *repository.SensoryStatsRepository, prefRepo *repository.SensoryPreferenceRepository) *PlaceHandler {
+	return &PlaceHandler{
+		placeRepo: placeRepo,
+		nearby:    nearby,
+		statsRepo: statsRepo,
+		prefRepo:  prefRepo,
+	}
+}
+
+// placeFits scores places against the caller's sensory profile, keyed by
+// place ID. It returns nil if the caller has not set a profile.
+func (h *PlaceHandler) placeFits(c *gin.Context, placeIDs []int64) (map[int64]*models.PlaceFit, error) {
+	if len(placeIDs) == 0 {
+		return nil, nil
+	}
+
+	prefs, err := h.prefRepo.FindByUserID(c.GetInt64("userID"))
+	if err != nil || len(prefs) == 0 {
+		return nil, err
+	}
+
+	stats, err := h.statsRepo.FindByPlaceIDs(placeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	fits := make(map[int64]*models.PlaceFit, len(placeIDs))
+	for _, placeID := range placeIDs {
+		fits[placeID] = services.FitPlace(prefs, stats[placeID])
+	}
+	return fits, nil
+}
+
+// GET /api/places
+func (h *PlaceHandler) GetPlaces(c *gin.Context) {
+	category := c.Query("category")
+	limit, offset, ok := parsePage(c, 20, maxPlacesLimit)
+	if !ok {
+		return
+	}
+
+	places, err := h.placeRepo.FindAll(category, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch places"})
+		return
+	}
+
+	total, err := h.placeRepo.Count(category)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch places"})
+		return
+	}
+
+	placeIDs := make([]int64, 0, len(places))
+	for _, place := range places {
+		placeIDs = append(placeIDs, place.ID)
+	}
+	fits, err := h.placeFits(c, placeIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch places"})
+		return
+	}
+
+	response := make([]models.PlaceResponse, 0, len(places))
+	for _, place := range places {
+		resp := place.ToResponse()
+		resp.Fit = fits[place.ID]
+		response = append(response, resp)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"places": response,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GET /api/places/nearby
+func (h *PlaceHandler) GetNearbyPlaces(c *gin.Context) {
+	lat, err := strconv.ParseFloat(c.Query("lat"), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat must be a number between -90 and 90"})
+		return
+	}
+	lon, err := strconv.ParseFloat(c.Query("lon"), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lon must be a number between -180 and 180"})
+		return
+	}
+	radius, err := strconv.ParseFloat(c.DefaultQuery("radius_m", "1000"), 64)
+	if err != nil || radius <= 0 || radius > maxNearbyRadiusM {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "radius_m must be between 0 and " + strconv.Itoa(maxNearbyRadiusM)})
+		return
+	}
+	limit, ok := parseLimit(c, 20, maxPlacesLimit)
+	if !ok {
+		return
+	}
+
+	places, err := h.nearby.FindNearby(lat, lon, radius, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch places"})
+		return
+	}
+
+	placeIDs := make([]int64, 0, len(places))
+	for _, place := range places {
+		placeIDs = append(placeIDs, place.ID)
+	}
+	fits, err := h.placeFits(c, placeIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch places"})
+		return
+	}
+
+	response := make([]models.NearbyPlaceResponse, 0, len(places))
+	for _, place := range places {
+		resp := place.ToResponse()
+		resp.Fit = fits[place.ID]
+		response = append(response, resp)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"places":   response,
+		"radius_m": radius,
+	})
+}
+
+// GET /api/places/search
+func (h *PlaceHandler) SearchPlaces(c *gin.Context) {
+	filter := &models.PlaceSearchFilter{
+		Category: c.Query("category"),
+		Ratings:  make(map[string]models.RatingRange),
+		UserID:   c.GetInt64("userID"),
+	}
+
+	if c.Query("lat") != "" || c.Query("lon") != "" || c.Query("radius_m") != "" {
+		lat, err := strconv.ParseFloat(c.Query("lat"), 64)
+		if err != nil || lat < -90 || lat > 90 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lat must be a number between -90 and 90"})
+			return
+		}
+		lon, err := strconv.ParseFloat(c.Query("lon"), 64)
+		if err != nil || lon < -180 || lon > 180 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lon must be a number between -180 and 180"})
+			return
+		}
+		radius, err := strconv.ParseFloat(c.DefaultQuery("radius_m", "1000"), 64)
+		if err != nil || radius <= 0 || radius > maxNearbyRadiusM {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "radius_m must be between 0 and " + strconv.Itoa(maxNearbyRadiusM)})
+			return
+		}
+		filter.Near = &models.GeoCircle{Lat: lat, Lon: lon, RadiusM: radius}
+	}
+
+	for _, dimension := range models.SensoryDimensions {
+		var bounds models.RatingRange
+		for _, bound := range []struct {
+			param string
+			value **float64
+		}{
+			{dimension + "_min", &bounds.Min},
+			{dimension + "_max", &bounds.Max},
+		} {
+			param := c.Query(bound.param)
+			if param == "" {
+				continue
+			}
+			value, err := strconv.ParseFloat(param, 64)
+			if err != nil || value < 1 || value > 5 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": bound.param + " must be a number between 1 and 5"})
+				return
+			}
+			*bound.value = &value
+		}
+		if bounds.Min != nil && bounds.Max != nil && *bounds.Min > *bounds.Max {
+			c.JSON(http.StatusBadRequest, gin.H{"error": dimension + "_min must not exceed " + dimension + "_max"})
+			return
+		}
+		if bounds.Min != nil || bounds.Max != nil {
+			filter.Ratings[dimension] = bounds
+		}
+	}
+
+	if param := c.Query("min_reviews"); param != "" {
+		minReviews, err := strconv.Atoi(param)
+		if err != nil || minReviews < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_reviews must be a non-negative integer"})
+			return
+		}
+		filter.MinReviews = minReviews
+	}
+
+	var ok bool
+	if filter.Limit, ok = parseLimit(c, 20, maxPlacesLimit); !ok {
+		return
+	}
+
+	// Nearest first by default when searching an area
+	defaultSort := models.PlaceSortRating
+	if filter.Near != nil {
+		defaultSort = models.PlaceSortDistance
+	}
+	filter.Sort = c.DefaultQuery("sort", defaultSort)
+	switch filter.Sort {
+	case models.PlaceSortRating, models.PlaceSortReviewCount:
+	case models.PlaceSortDistance:
+		if filter.Near == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "sorting by distance needs lat and lon"})
+			return
+		}
+	case models.PlaceSortFit:
+		prefs, err := h.prefRepo.FindByUserID(filter.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search places"})
+			return
+		}
+		if len(prefs) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "set a sensory profile to sort by fit"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be one of fit, distance, rating, review_count"})
+		return
+	}
+
+	if cursor := c.Query("cursor"); cursor != "" {
+		after, err := models.DecodePlaceSearchCursor(cursor)
+		if err != nil || after.Sort != filter.Sort {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		filter.After = after
+	}
+
+	// One extra place tells whether there is a next page
+	filter.Limit++
+	results, err := h.placeRepo.Search(filter)
+	if err != nil {
+		log.Printf("Failed to search places: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search places"})
+		return
+	}
+
+	var nextCursor *string
+	if len(results) == filter.Limit {
+		results = results[:len(results)-1]
+		last := results[len(results)-1]
+		cursor := (&models.PlaceSearchCursor{Sort: filter.Sort, Value: last.SortValue, ID: last.ID}).Encode()
+		nextCursor = &cursor
+	}
+
+	placeIDs := make([]int64, 0, len(results))
+	for _, result := range results {
+		placeIDs = append(placeIDs, result.ID)
+	}
+	fits, err := h.placeFits(c, placeIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search places"})
+		return
+	}
+
+	response := make([]models.PlaceSearchResponse, 0, len(results))
+	for _, result := range results {
+		resp := result.ToResponse()
+		resp.Fit = fits[result.ID]
+		response = append(response, resp)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"places":      response,
+		"sort":        filter.Sort,
+		"next_cursor": nextCursor,
+	})
+}
+
+// GET /api/search
+func (h *PlaceHandler) FullTextSearch(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" || utf8.RuneCountInString(query) > maxSearchQueryLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q must be between 1 and " + strconv.Itoa(maxSearchQueryLength) + " characters"})
+		return
+	}
+	limit, offset, ok := parsePage(c, 20, maxPlacesLimit)
+	if !ok {
+		return
+	}
+
+	matches, err := h.placeRepo.SearchText(query, limit, offset)
+	if err != nil {
+		log.Printf("Failed to search places for %q: %v", query, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search"})
+		return
+	}
+
+	placeIDs := make([]int64, 0, len(matches))
+	for _, match := range matches {
+		placeIDs = append(placeIDs, match.ID)
+	}
+
+	snippets, err := h.placeRepo.FindReviewSnippets(query, placeIDs, searchSnippetsPerPlace)
+	if err != nil {
+		log.Printf("Failed to find review snippets for %q: %v", query, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search"})
+		return
+	}
+
+	fits, err := h.placeFits(c, placeIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search"})
+		return
+	}
+
+	response := make([]models.PlaceTextMatchResponse, 0, len(matches))
+	byPlace := make(map[int64]int, len(matches))
+	for _, match := range matches {
+		resp := match.ToResponse()
+		resp.Fit = fits[match.ID]
+		byPlace[match.ID] = len(response)
+		response = append(response, resp)
+	}
+	for _, snippet := range snippets {
+		i := byPlace[snippet.PlaceID]
+		response[i].Snippets = append(response[i].Snippets, snippet)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"query":  query,
+		"places": response,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// GET /api/search/autocomplete
+func (h *PlaceHandler) Autocomplete(c *gin.Context) {
+	prefix := strings.TrimSpace(c.Query("q"))
+	limit, ok := parseLimit(c, 10, maxAutocompleteLimit)
+	if !ok {
+		return
+	}
+
+	// Trigrams of a single character match almost anything
+	if utf8.RuneCountInString(prefix) < minAutocompleteLength {
+		c.JSON(http.StatusOK, gin.H{"places": []models.PlaceResponse{}})
+		return
+	}
+	if utf8.RuneCountInString(prefix) > maxSearchQueryLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q must be at most " + strconv.Itoa(maxSearchQueryLength) + " characters"})
+		return
+	}
+
+	places, err := h.placeRepo.Autocomplete(prefix, limit)
+	if err != nil {
+		log.Printf("Failed to autocomplete %q: %v", prefix, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search"})
+		return
+	}
+
+	response := make([]models.PlaceResponse, 0, len(places))
+	for _, place := range places {
+		response = append(response, place.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{"places": response})
+}
+
+// GET /api/places/:id
+func (h *PlaceHandler) GetPlace(c *gin.Context) {
+	placeID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid place ID"})
+		return
+	}
+
+	place, err := h.placeRepo.FindByID(placeID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "place not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch place"})
+		return
+	}
+
+	fits, err := h.placeFits(c, []int64{place.ID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch place"})
+		return
+	}
+
+	resp := place.ToResponse()
+	resp.Fit = fits[place.ID]
+	c.JSON(http.StatusOK, resp)
+}
+
+// GET /api/places/:id/sensory-profile
+func (h *PlaceHandler) GetSensoryProfile(c *gin.Context) {
+	placeID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid place ID"})
+		return
+	}
+
+	place, err := h.placeRepo.FindByID(placeID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "place not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch place"})
+		return
+	}
+
+	switch c.Query("by") {
+	case "":
+	case "hour_of_week":
+		h.getSensoryProfileByHour(c, place)
+		return
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "by must be hour_of_week"})
+		return
+	}
+
+	stats, err := h.statsRepo.FindByPlaceID(placeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sensory profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewSensoryProfileResponse(placeID, place.ReviewCount, stats))
+}
+
+// getSensoryProfileByHour answers GetSensoryProfile with aggregates per
+// hour of the week and the best times to visit.
+func (h *PlaceHandler) getSensoryProfileByHour(c *gin.Context, place *models.Place) {
+	buckets, err := h.statsRepo.FindByHourOfWeek(place.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sensory profile"})
+		return
+	}
+
+	prefs, err := h.prefRepo.FindByUserID(c.GetInt64("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sensory profile"})
+		return
+	}
+
+	response := make([]models.SensoryTimeBucketResponse, 0, len(buckets))
+	for _, bucket := range buckets {
+		response = append(response, bucket.ToResponse())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"place_id":     place.ID,
+		"review_count": place.ReviewCount,
+		"by":           "hour_of_week",
+		"buckets":      response,
+		"best_times":   services.BestVisitTimes(buckets, prefs),
+	})
+}
+
+// POST /api/admin/sensory-stats/recompute
+func (h *PlaceHandler) RecomputeSensoryStats(c *gin.Context) {
+	var placeID int64
+	if param := c.Query("place_id"); param != "" {
+		id, err := strconv.ParseInt(param, 10, 64)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid place ID"})
+			return
+		}
+		placeID = id
+	}
+
+	if err := h.statsRepo.Recompute(placeID); err != nil {
+		log.Printf("Failed to recompute sensory stats: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to recompute sensory stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "sensory stats recomputed"})
+}
+
+// POST /api/places
+func (h *PlaceHandler) CreatePlace(c *gin.Context) {
+	var req models.CreatePlaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "latitude and longitude must be set together"})
+		return
+	}
+
+	place, err := h.placeRepo.Create(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create place"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, place.ToResponse())
+}
+
+// PUT /api/places/:id
+func (h *PlaceHandler) UpdatePlace(c *gin.Context) {
+	placeID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid place ID"})
+		return
+	}
+
+	var req models.UpdatePlaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "latitude and longitude must be set together"})
+		return
+	}
+
+	place, err := h.placeRepo.Update(placeID, &req)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "place not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update place"})
+		return
+	}
+
+	c.JSON(http.StatusOK, place.ToResponse())
+}
+
+// DELETE /api/places/:id
+func (h *PlaceHandler) DeletePlace(c *gin.Context) {
+	placeID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid place ID"})
+		return
+	}
+
+	err = h.placeRepo.Delete(placeID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "place not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete place"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "place deleted"})
+}
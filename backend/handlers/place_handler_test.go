@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"sensory-navigator/models"
+)
+
+// The requests below are all rejected before the repository is used, so
+// the handler needs none.
+
+func TestGetPlacesRejectsBadPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "limit too large", query: "limit=101"},
+		{name: "zero limit", query: "limit=0"},
+		{name: "negative offset", query: "offset=-20"},
+		{name: "offset not a number", query: "offset=x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/places?"+tt.query, nil)
+
+			(&PlaceHandler{}).GetPlaces(c)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestUpdatePlaceRequiresCoordinatePair(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "latitude only", body: `{"latitude": 55.75}`},
+		{name: "longitude only", body: `{"longitude": 37.62}`},
+		{name: "latitude out of range", body: `{"latitude": 91, "longitude": 37.62}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPut, "/api/places/1", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = gin.Params{{Key: "id", Value: "1"}}
+
+			(&PlaceHandler{}).UpdatePlace(c)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+// nearbyFinder records the search it was asked for.
+type nearbyFinder struct {
+	lat, lon, radiusM float64
+	limit             int
+	calls             int
+}
+
+func (f *nearbyFinder) FindNearby(lat, lon, radiusM float64, limit int) ([]*models.NearbyPlace, error) {
+	f.lat, f.lon, f.radiusM, f.limit = lat, lon, radiusM, limit
+	f.calls++
+	return nil, nil
+}
+
+func TestGetNearbyPlaces(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantRadius float64
+		wantLimit  int
+	}{
+		{name: "defaults", query: "lat=55.75&lon=37.62", wantStatus: http.StatusOK, wantRadius: 1000, wantLimit: 20},
+		{name: "explicit", query: "lat=55.75&lon=37.62&radius_m=50000&limit=100", wantStatus: http.StatusOK, wantRadius: 50000, wantLimit: 100},
+		{name: "missing lat", query: "lon=37.62", wantStatus: http.StatusBadRequest},
+		{name: "lat out of range", query: "lat=90.5&lon=37.62", wantStatus: http.StatusBadRequest},
+		{name: "lon out of range", query: "lat=55.75&lon=-181", wantStatus: http.StatusBadRequest},
+		{name: "radius too large", query: "lat=55.75&lon=37.62&radius_m=50001", wantStatus: http.StatusBadRequest},
+		{name: "zero radius", query: "lat=55.75&lon=37.62&radius_m=0", wantStatus: http.StatusBadRequest},
+		{name: "limit too large", query: "lat=55.75&lon=37.62&limit=101", wantStatus: http.StatusBadRequest},
+		{name: "negative limit", query: "lat=55.75&lon=37.62&limit=-5", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/places/nearby?"+tt.query, nil)
+			finder := &nearbyFinder{}
+
+			(&PlaceHandler{nearby: finder}).GetNearbyPlaces(c)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if finder.calls != 0 {
+					t.Error("searched despite the invalid request")
+				}
+				return
+			}
+			if finder.radiusM != tt.wantRadius || finder.limit != tt.wantLimit {
+				t.Errorf("FindNearby(radius %v, limit %d), want (radius %v, limit %d)", finder.radiusM, finder.limit, tt.wantRadius, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestSearchPlacesRejectsBadFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	distanceCursor := (&models.PlaceSearchCursor{Sort: models.PlaceSortDistance, Value: 120, ID: 3}).Encode()
+
+	tests := []struct {
+		name  string
+		query string
+	}{
+		{name: "radius without a point", query: "radius_m=500"},
+		{name: "lat without lon", query: "lat=55.75"},
+		{name: "rating below the scale", query: "lighting_min=0.5"},
+		{name: "rating above the scale", query: "crowding_max=6"},
+		{name: "min above max", query: "sound_level_min=4&sound_level_max=2"},
+		{name: "negative min_reviews", query: "min_reviews=-1"},
+		{name: "limit too large", query: "limit=101"},
+		{name: "unknown sort", query: "sort=name"},
+		{name: "distance sort without a point", query: "sort=distance"},
+		{name: "malformed cursor", query: "cursor=abc"},
+		{name: "cursor of another sort", query: "sort=rating&cursor=" + distanceCursor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/places/search?"+tt.query, nil)
+
+			(&PlaceHandler{}).SearchPlaces(c)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestTextSearchRejectsBadQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	longQuery := strings.Repeat("я", maxSearchQueryLength+1)
+
+	tests := []struct {
+		name   string
+		path   string
+		search func(h *PlaceHandler, c *gin.Context)
+	}{
+		{name: "search without q", path: "/api/search?q=+", search: (*PlaceHandler).FullTextSearch},
+		{name: "search q too long", path: "/api/search?q=" + longQuery, search: (*PlaceHandler).FullTextSearch},
+		{name: "search limit too large", path: "/api/search?q=quiet&limit=101", search: (*PlaceHandler).FullTextSearch},
+		{name: "search negative offset", path: "/api/search?q=quiet&offset=-1", search: (*PlaceHandler).FullTextSearch},
+		{name: "autocomplete limit too large", path: "/api/search/autocomplete?q=caf&limit=21", search: (*PlaceHandler).Autocomplete},
+		{name: "autocomplete zero limit", path: "/api/search/autocomplete?q=caf&limit=0", search: (*PlaceHandler).Autocomplete},
+		{name: "autocomplete limit not a number", path: "/api/search/autocomplete?q=caf&limit=all", search: (*PlaceHandler).Autocomplete},
+		{name: "autocomplete q too long", path: "/api/search/autocomplete?q=" + longQuery, search: (*PlaceHandler).Autocomplete},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			tt.search(&PlaceHandler{}, c)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+			}
+		})
+	}
+}
+
+func TestAutocompleteIgnoresShortPrefix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/search/autocomplete?q=c", nil)
+
+	(&PlaceHandler{}).Autocomplete(c)
+
+	if w.Code != http.StatusOK || w.Body.String() != `{"places":[]}` {
+		t.Errorf("response = %d %s, want 200 with no places", w.Code, w.Body.String())
+	}
+}
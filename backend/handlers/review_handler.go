@@ -8,14 +8,21 @@ import (
 
 	"sensory-navigator/models"
 	"sensory-navigator/repository"
+	"sensory-navigator/services"
 )
 
 type ReviewHandler struct {
-	reviewRepo *repository.ReviewRepository
+	reviewRepo           *repository.ReviewRepository
+	authService          *services.AuthService
+	requireVerifiedEmail bool
 }
 
-func NewReviewHandler(reviewRepo *repository.ReviewRepository) *ReviewHandler {
-	return &ReviewHandler{reviewRepo: reviewRepo}
+func NewReviewHandler(reviewRepo *repository.ReviewRepository, authService *services.AuthService, requireVerifiedEmail bool) *ReviewHandler {
+	return &ReviewHandler{
+		reviewRepo:           reviewRepo,
+		authService:          authService,
+		requireVerifiedEmail: requireVerifiedEmail,
+	}
 }
 
 // GET /api/places/:id/reviews
@@ -52,6 +59,18 @@ func (h *ReviewHandler) CreateReview(c *gin.Context) {
 		return
 	}
 
+	if h.requireVerifiedEmail {
+		verified, err := h.authService.IsEmailVerified(userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check email verification"})
+			return
+		}
+		if !verified {
+			c.JSON(http.StatusForbidden, gin.H{"error": "please verify your email before posting reviews"})
+			return
+		}
+	}
+
 	// Check if user already reviewed this place
 	exists, _ := h.reviewRepo.ExistsByUserAndPlace(userID, placeID)
 	if exists {
@@ -65,7 +84,17 @@ func (h *ReviewHandler) CreateReview(c *gin.Context) {
 		return
 	}
 
-	review, err := h.reviewRepo.Create(userID, placeID, &req)
+	visitHourOfWeek, err := req.HourOfWeek()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	review, err := h.reviewRepo.Create(userID, placeID, &req, visitHourOfWeek)
+	if repository.IsForeignKeyViolation(err) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "place not found"})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create review"})
 		return
@@ -84,14 +113,14 @@ func (h *ReviewHandler) UpdateReview(c *gin.Context) {
 		return
 	}
 
-	// Check ownership
+	// Check ownership; moderators may change any review
 	existingReview, err := h.reviewRepo.FindByID(reviewID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "review not found"})
 		return
 	}
 
-	if existingReview.UserID != userID {
+	if existingReview.UserID != userID && !models.CanModerate(c.GetString("role")) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "you can only edit your own reviews"})
 		return
 	}
@@ -102,7 +131,13 @@ func (h *ReviewHandler) UpdateReview(c *gin.Context) {
 		return
 	}
 
-	review, err := h.reviewRepo.Update(reviewID, &req)
+	visitHourOfWeek, err := req.HourOfWeek()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	review, err := h.reviewRepo.Update(reviewID, &req, visitHourOfWeek)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update review"})
 		return
@@ -121,14 +156,14 @@ func (h *ReviewHandler) DeleteReview(c *gin.Context) {
 		return
 	}
 
-	// Check ownership
+	// Check ownership; moderators may change any review
 	existingReview, err := h.reviewRepo.FindByID(reviewID)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "review not found"})
 		return
 	}
 
-	if existingReview.UserID != userID {
+	if existingReview.UserID != userID && !models.CanModerate(c.GetString("role")) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "you can only delete your own reviews"})
 		return
 	}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"sensory-navigator/models"
+	"sensory-navigator/services"
+)
+
+type TwoFactorHandler struct {
+	authService *services.AuthService
+}
+
+func NewTwoFactorHandler(authService *services.AuthService) *TwoFactorHandler {
+	return &TwoFactorHandler{authService: authService}
+}
+
+// POST /api/users/me/2fa/setup
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	userID := c.GetInt64("userID")
+
+	setup, err := h.authService.SetupTwoFactor(userID)
+	if err != nil {
+		writeTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// POST /api/users/me/2fa/confirm
+func (h *TwoFactorHandler) Confirm(c *gin.Context) {
+	userID := c.GetInt64("userID")
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.authService.ConfirmTwoFactor(userID, req.Code, clientInfo(c))
+	if err != nil {
+		writeTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// POST /api/users/me/2fa/disable
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	userID := c.GetInt64("userID")
+
+	var req models.DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.authService.DisableTwoFactor(userID, req.Password, req.Code, clientInfo(c)); err != nil {
+		writeTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
+
+// POST /api/users/me/2fa/recovery-codes
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID := c.GetInt64("userID")
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.authService.RegenerateRecoveryCodes(userID, req.Code, clientInfo(c))
+	if err != nil {
+		writeTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+func writeTwoFactorError(c *gin.Context, err error) {
+	if writeThrottleError(c, err) {
+		return
+	}
+	switch err {
+	case services.ErrTwoFactorAlreadyEnabled:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case services.ErrTwoFactorNotSetUp:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case services.ErrInvalidTwoFactorCode, services.ErrInvalidCredentials:
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "two-factor operation failed"})
+	}
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"database/sql"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -8,19 +10,26 @@ import (
 
 	"sensory-navigator/models"
 	"sensory-navigator/repository"
+	"sensory-navigator/services"
 )
 
 type UserHandler struct {
 	userRepo     *repository.UserRepository
 	reviewRepo   *repository.ReviewRepository
 	favoriteRepo *repository.FavoriteRepository
+	prefRepo     *repository.SensoryPreferenceRepository
+	authService  *services.AuthService
+	auditor      services.Auditor
 }
 
-func NewUserHandler(userRepo *repository.UserRepository, reviewRepo *repository.ReviewRepository, favoriteRepo *repository.FavoriteRepository) *UserHandler {
+func NewUserHandler(userRepo *repository.UserRepository, reviewRepo *repository.ReviewRepository, favoriteRepo *repository.FavoriteRepository, prefRepo *repository.SensoryPreferenceRepository, authService *services.AuthService, auditor services.Auditor) *UserHandler {
 	return &UserHandler{
 		userRepo:     userRepo,
 		reviewRepo:   reviewRepo,
 		favoriteRepo: favoriteRepo,
+		prefRepo:     prefRepo,
+		authService:  authService,
+		auditor:      auditor,
 	}
 }
 
@@ -53,9 +62,59 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	// Only the names of the changed fields are logged
+	var fields []string
+	if req.Username != nil {
+		fields = append(fields, "username")
+	}
+	if req.AvatarURL != nil {
+		fields = append(fields, "avatar_url")
+	}
+	if req.BirthDate != nil {
+		fields = append(fields, "birth_date")
+	}
+	h.audit(c, models.EventProfileUpdated, map[string]interface{}{"fields": fields})
+
 	c.JSON(http.StatusOK, user.ToResponse())
 }
 
+// GET /api/users/me/sensory-profile
+func (h *UserHandler) GetSensoryProfile(c *gin.Context) {
+	prefs, err := h.prefRepo.FindByUserID(c.GetInt64("userID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sensory profile"})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewUserSensoryProfile(prefs))
+}
+
+// PUT /api/users/me/sensory-profile
+func (h *UserHandler) UpdateSensoryProfile(c *gin.Context) {
+	userID := c.GetInt64("userID")
+
+	var req models.UpdateUserSensoryProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.prefRepo.Replace(userID, req.Dimensions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update sensory profile"})
+		return
+	}
+
+	prefs, err := h.prefRepo.FindByUserID(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sensory profile"})
+		return
+	}
+
+	h.audit(c, models.EventProfileUpdated, map[string]interface{}{"fields": []string{"sensory_profile"}})
+
+	c.JSON(http.StatusOK, models.NewUserSensoryProfile(prefs))
+}
+
 // GET /api/users/me/reviews
 func (h *UserHandler) GetMyReviews(c *gin.Context) {
 	userID := c.GetInt64("userID")
@@ -99,3 +158,58 @@ func (h *UserHandler) GetMyFavorites(c *gin.Context) {
 	})
 }
 
+
+// GET /api/users/me/sessions
+func (h *UserHandler) GetMySessions(c *gin.Context) {
+	userID := c.GetInt64("userID")
+	currentSessionID := c.GetString("sessionID")
+
+	sessions, err := h.userRepo.FindSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch sessions"})
+		return
+	}
+
+	response := make([]models.SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		resp := session.ToResponse()
+		resp.Current = session.FamilyID == currentSessionID
+		response = append(response, resp)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": response})
+}
+
+// DELETE /api/users/me/sessions/:id
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	userID := c.GetInt64("userID")
+
+	sessionID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session ID"})
+		return
+	}
+
+	err = h.authService.RevokeSession(userID, sessionID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+
+	h.audit(c, models.EventSessionRevoked, map[string]interface{}{"session_id": sessionID})
+
+	c.JSON(http.StatusOK, gin.H{"message": "session revoked"})
+}
+
+// audit records an event the signed-in user did to their account. The
+// change has already been made, so a failure is only logged.
+func (h *UserHandler) audit(c *gin.Context, eventType string, details map[string]interface{}) {
+	userID := c.GetInt64("userID")
+	if err := h.auditor.Record(services.NewAuditEvent(eventType, userID, userID, clientInfo(c), details)); err != nil {
+		log.Printf("Failed to record %s for user %d: %v", eventType, userID, err)
+	}
+}
@@ -0,0 +1,54 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LogMailer is a development mailer. It writes each message to the log,
+// or to an .eml file in outboxDir when one is configured.
+type LogMailer struct {
+	outboxDir string
+}
+
+func NewLogMailer(outboxDir string) *LogMailer {
+	return &LogMailer{outboxDir: outboxDir}
+}
+
+func (m *LogMailer) Send(msg *Message) error {
+	if m.outboxDir == "" {
+		log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Text)
+		return nil
+	}
+
+	if err := os.MkdirAll(m.outboxDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create outbox directory: %w", err)
+	}
+
+	body, err := buildMIME("outbox@localhost", msg)
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), sanitizeFileName(msg.To))
+	path := filepath.Join(m.outboxDir, name)
+	if err := os.WriteFile(path, body, 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %w", err)
+	}
+
+	log.Printf("Email to %s written to %s", msg.To, path)
+	return nil
+}
+
+func sanitizeFileName(s string) string {
+	out := []rune(s)
+	for i, r := range out {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-') {
+			out[i] = '_'
+		}
+	}
+	return string(out)
+}
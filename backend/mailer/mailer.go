@@ -0,0 +1,32 @@
+package mailer
+
+import (
+	"fmt"
+
+	"sensory-navigator/config"
+)
+
+// Message is a single outgoing email with plain-text and HTML alternatives.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers outgoing emails.
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+// New returns the mailer selected by MAIL_DRIVER.
+func New(cfg *config.Config) (Mailer, error) {
+	switch cfg.Mail.Driver {
+	case "smtp":
+		return NewSMTPMailer(&cfg.SMTP, cfg.Mail.From), nil
+	case "log", "":
+		return NewLogMailer(cfg.Mail.OutboxDir), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Mail.Driver)
+	}
+}
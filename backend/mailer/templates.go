@@ -0,0 +1,56 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+// DefaultLanguage is used when a user has no language preference or the
+// requested translation does not exist.
+const DefaultLanguage = "ru"
+
+//go:embed templates/*
+var templateFS embed.FS
+
+// Render builds a message from templates/<name>.<lang>.txt and
+// templates/<name>.<lang>.html. The text template must define a
+// "subject" block.
+func Render(name, lang string, data interface{}) (*Message, error) {
+	if _, err := templateFS.Open(templatePath(name, lang, "txt")); err != nil {
+		lang = DefaultLanguage
+	}
+
+	textTmpl, err := texttemplate.ParseFS(templateFS, templatePath(name, lang, "txt"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse text template: %w", err)
+	}
+	htmlTmpl, err := htmltemplate.ParseFS(templateFS, templatePath(name, lang, "html"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html template: %w", err)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := textTmpl.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err := textTmpl.Execute(&text, data); err != nil {
+		return nil, err
+	}
+	if err := htmlTmpl.Execute(&html, data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()),
+		HTML:    html.String(),
+	}, nil
+}
+
+func templatePath(name, lang, ext string) string {
+	return fmt.Sprintf("templates/%s.%s.%s", name, lang, ext)
+}
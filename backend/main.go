@@ -2,13 +2,16 @@ package main
 
 import (
 	"log"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
 	"sensory-navigator/config"
 	"sensory-navigator/database"
 	"sensory-navigator/handlers"
+	"sensory-navigator/mailer"
 	"sensory-navigator/middleware"
+	"sensory-navigator/models"
 	"sensory-navigator/repository"
 	"sensory-navigator/services"
 )
@@ -30,15 +33,107 @@ func main() {
 	userRepo := repository.NewUserRepository(database.GetDB())
 	reviewRepo := repository.NewReviewRepository(database.GetDB())
 	favoriteRepo := repository.NewFavoriteRepository(database.GetDB())
+	placeRepo := repository.NewPlaceRepository(database.GetDB())
+	auditRepo := repository.NewAuditEventRepository(database.GetDB())
+	twoFactorRepo := repository.NewTwoFactorRepository(database.GetDB())
+	identityRepo := repository.NewIdentityRepository(database.GetDB())
+	apiKeyRepo := repository.NewAPIKeyRepository(database.GetDB())
+	sensoryStatsRepo := repository.NewSensoryStatsRepository(database.GetDB())
+	sensoryPrefRepo := repository.NewSensoryPreferenceRepository(database.GetDB())
+
+	// Initialize mailer
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// Load token signing keys
+	keys, err := services.LoadKeySet(&cfg.JWT)
+	if err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	// Select where failed login counters live
+	var attemptStore services.LoginAttemptStore
+	switch cfg.Login.Store {
+	case "postgres":
+		attemptStore = repository.NewLoginAttemptRepository(database.GetDB())
+	case "memory":
+		attemptStore = services.NewMemoryLoginAttemptStore(cfg.Login.LockDuration)
+	default:
+		log.Fatalf("Unknown login throttle store: %s", cfg.Login.Store)
+	}
+	loginThrottle := services.NewLoginThrottle(attemptStore, &cfg.Login)
+
+	// Limits how often emails can be requested
+	mailLimiter := services.NewRateLimiter(cfg.Auth.MailRequestWindow)
+
+	// Select how nearby places are searched
+	var nearbyFinder repository.NearbyPlaceFinder
+	switch cfg.Places.GeoBackend {
+	case "postgis":
+		nearbyFinder = repository.NewPostGISPlaceRepository(database.GetDB())
+	case "sql":
+		nearbyFinder = placeRepo
+	default:
+		log.Fatalf("Unknown places geo backend: %s", cfg.Places.GeoBackend)
+	}
+
+	// Access token revocation state, cached for the per-request checks
+	revocation := services.NewTokenRevocationCache(repository.NewTokenRevocationRepository(database.GetDB()), cfg.JWT.RevocationCacheTTL)
+
+	// Requirements for new passwords, with the optional breach corpus
+	passwordPolicy, err := services.NewPasswordPolicy(&cfg.Password)
+	if err != nil {
+		log.Fatalf("Failed to load password policy: %v", err)
+	}
+
+	passwordHasher, err := services.NewPasswordHasher(&cfg.Hash)
+	if err != nil {
+		log.Fatalf("Failed to configure password hashing: %v", err)
+	}
 
 	// Initialize services
-	authService := services.NewAuthService(userRepo, &cfg.JWT)
+	authService := services.NewAuthService(userRepo, twoFactorRepo, apiKeyRepo, auditRepo, &cfg.JWT, keys, revocation, loginThrottle, mailLimiter, passwordPolicy, passwordHasher, &cfg.Auth, mail, &cfg.Mail)
+
+	// Grant the admin role to the configured accounts
+	missingAdmins, err := authService.BootstrapAdmins()
+	if err != nil {
+		log.Fatalf("Failed to bootstrap admins: %v", err)
+	}
+	for _, email := range missingAdmins {
+		log.Printf("Admin account %s does not exist yet; register it and restart", email)
+	}
+
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, &cfg.JWT, keys, revocation)
+	exportService := services.NewExportService(userRepo, reviewRepo, favoriteRepo, sensoryPrefRepo)
+
+	oidcService := services.NewOIDCService(&cfg.OIDC, userRepo, identityRepo, authService)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
-	userHandler := handlers.NewUserHandler(userRepo, reviewRepo, favoriteRepo)
-	reviewHandler := handlers.NewReviewHandler(reviewRepo)
+	userHandler := handlers.NewUserHandler(userRepo, reviewRepo, favoriteRepo, sensoryPrefRepo, authService, auditRepo)
+	reviewHandler := handlers.NewReviewHandler(reviewRepo, authService, cfg.Auth.RequireVerifiedEmail)
 	favoriteHandler := handlers.NewFavoriteHandler(favoriteRepo)
+	placeHandler := handlers.NewPlaceHandler(placeRepo, nearbyFinder, sensoryStatsRepo, sensoryPrefRepo)
+	twoFactorHandler := handlers.NewTwoFactorHandler(authService)
+	oidcHandler := handlers.NewOIDCHandler(oidcService, cfg.Mail.AppURL)
+	adminHandler := handlers.NewAdminHandler(userRepo, authService, auditRepo)
+	accountHandler := handlers.NewAccountHandler(authService, exportService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	auditHandler := handlers.NewAuditHandler(auditRepo)
+
+	// Purge accounts whose deletion grace period has ended
+	go func() {
+		for ; ; time.Sleep(time.Hour) {
+			purged, err := authService.PurgeDeletedAccounts()
+			if err != nil {
+				log.Printf("Failed to purge deleted accounts: %v", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d deleted accounts", purged)
+			}
+		}
+	}()
 
 	// Setup router
 	router := gin.Default()
@@ -47,7 +142,7 @@ func main() {
 	router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
 		
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -65,11 +160,37 @@ func main() {
 		{
 			auth.POST("/register", authHandler.Register)
 			auth.POST("/login", authHandler.Login)
+			auth.POST("/login/2fa", authHandler.LoginTwoFactor)
 			auth.POST("/refresh", authHandler.Refresh)
+			auth.POST("/logout", authHandler.Logout)
+			auth.POST("/logout-all", middleware.AuthMiddleware(authService), authHandler.LogoutAll)
 			auth.POST("/forgot-password", authHandler.ForgotPassword)
 			auth.POST("/reset-password", authHandler.ResetPassword)
+			auth.POST("/magic-link", authHandler.RequestMagicLink)
+			auth.POST("/magic-link/verify", authHandler.VerifyMagicLink)
+			auth.GET("/oidc/:provider/start", oidcHandler.Start)
+			auth.GET("/oidc/:provider/callback", oidcHandler.Callback)
+			auth.POST("/verify-email", authHandler.VerifyEmail)
+			auth.POST("/resend-verification", authHandler.ResendVerification)
+			auth.POST("/confirm-email-change", accountHandler.ConfirmEmailChange)
+			auth.POST("/undo-email-change", accountHandler.UndoEmailChange)
+			auth.POST("/reauthenticate", authHandler.RequestReauthentication)
+			auth.POST("/restore-account", accountHandler.RestoreAccount)
 		}
 
+		// OAuth2 token endpoint for API clients
+		api.POST("/oauth/token", apiKeyHandler.Token)
+
+		// Routes open to API clients with the matching scope
+		api.GET("/places", middleware.ScopedAuthMiddleware(authService, apiKeyService, models.ScopePlacesRead), placeHandler.GetPlaces)
+		api.GET("/search", middleware.ScopedAuthMiddleware(authService, apiKeyService, models.ScopePlacesRead), placeHandler.FullTextSearch)
+		api.GET("/search/autocomplete", middleware.ScopedAuthMiddleware(authService, apiKeyService, models.ScopePlacesRead), placeHandler.Autocomplete)
+		api.GET("/places/search", middleware.ScopedAuthMiddleware(authService, apiKeyService, models.ScopePlacesRead), placeHandler.SearchPlaces)
+		api.GET("/places/nearby", middleware.ScopedAuthMiddleware(authService, apiKeyService, models.ScopePlacesRead), placeHandler.GetNearbyPlaces)
+		api.GET("/places/:id", middleware.ScopedAuthMiddleware(authService, apiKeyService, models.ScopePlacesRead), placeHandler.GetPlace)
+		api.GET("/places/:id/sensory-profile", middleware.ScopedAuthMiddleware(authService, apiKeyService, models.ScopePlacesRead), placeHandler.GetSensoryProfile)
+		api.GET("/places/:id/reviews", middleware.ScopedAuthMiddleware(authService, apiKeyService, models.ScopeReviewsRead), reviewHandler.GetPlaceReviews)
+
 		// Protected routes
 		protected := api.Group("")
 		protected.Use(middleware.AuthMiddleware(authService))
@@ -79,16 +200,40 @@ func main() {
 			{
 				users.GET("/me", userHandler.GetProfile)
 				users.PUT("/me", userHandler.UpdateProfile)
+				users.DELETE("/me", accountHandler.DeleteAccount)
+				users.GET("/me/export", accountHandler.Export)
+				users.PUT("/me/password", accountHandler.ChangePassword)
+				users.POST("/me/email", accountHandler.RequestEmailChange)
 				users.GET("/me/reviews", userHandler.GetMyReviews)
 				users.GET("/me/favorites", userHandler.GetMyFavorites)
+				users.GET("/me/sensory-profile", userHandler.GetSensoryProfile)
+				users.PUT("/me/sensory-profile", userHandler.UpdateSensoryProfile)
+				users.GET("/me/sessions", userHandler.GetMySessions)
+				users.DELETE("/me/sessions/:id", userHandler.RevokeSession)
+				users.GET("/me/security-events", auditHandler.GetMySecurityEvents)
+				users.POST("/me/2fa/setup", twoFactorHandler.Setup)
+				users.POST("/me/2fa/confirm", twoFactorHandler.Confirm)
+				users.POST("/me/2fa/disable", twoFactorHandler.Disable)
+				users.POST("/me/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+				users.GET("/me/api-keys", apiKeyHandler.List)
+				users.POST("/me/api-keys", apiKeyHandler.Create)
+				users.DELETE("/me/api-keys/:id", apiKeyHandler.Revoke)
 			}
 
 			// Review routes
-			protected.GET("/places/:id/reviews", reviewHandler.GetPlaceReviews)
 			protected.POST("/places/:id/reviews", reviewHandler.CreateReview)
 			protected.PUT("/reviews/:id", reviewHandler.UpdateReview)
 			protected.DELETE("/reviews/:id", reviewHandler.DeleteReview)
 
+			// Place management, moderators and admins only
+			places := protected.Group("/places")
+			places.Use(middleware.RequireRole(models.RoleModerator, models.RoleAdmin))
+			{
+				places.POST("", placeHandler.CreatePlace)
+				places.PUT("/:id", placeHandler.UpdatePlace)
+				places.DELETE("/:id", placeHandler.DeletePlace)
+			}
+
 			// Favorite routes
 			favorites := protected.Group("/favorites")
 			{
@@ -96,9 +241,24 @@ func main() {
 				favorites.DELETE("/:placeId", favoriteHandler.RemoveFavorite)
 				favorites.GET("/:placeId/check", favoriteHandler.CheckFavorite)
 			}
+
+			// Admin routes
+			admin := protected.Group("/admin")
+			admin.Use(middleware.RequireRole(models.RoleAdmin))
+			{
+				admin.GET("/users", adminHandler.ListUsers)
+				admin.GET("/users/:id", adminHandler.GetUser)
+				admin.PUT("/users/:id/role", adminHandler.UpdateRole)
+				admin.DELETE("/users/:id/sessions", adminHandler.RevokeSessions)
+				admin.GET("/audit-events", auditHandler.ListEvents)
+				admin.POST("/sensory-stats/recompute", placeHandler.RecomputeSensoryStats)
+			}
 		}
 	}
 
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
+
 	// Health check
 	router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "ok"})
@@ -9,36 +9,136 @@ import (
 	"sensory-navigator/services"
 )
 
+// AuthMiddleware accepts access tokens of signed-in users only. Tokens
+// issued to API clients are refused, use ScopedAuthMiddleware for routes
+// that machine clients may call.
 func AuthMiddleware(authService *services.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header required"})
-			c.Abort()
+		claims, ok := bearerClaims(c, authService)
+		if !ok {
 			return
 		}
 
-		// Extract token from "Bearer <token>"
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
+		if claims.ClientID != "" {
+			c.JSON(http.StatusForbidden, gin.H{"error": "this endpoint requires a user session"})
 			c.Abort()
 			return
 		}
 
-		tokenString := parts[1]
+		// Set user ID, role and session in context
+		c.Set("userID", claims.UserID)
+		c.Set("role", claims.Role)
+		c.Set("sessionID", claims.SessionID)
+		c.Next()
+	}
+}
 
-		// Validate token
-		claims, err := authService.ValidateAccessToken(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
-			c.Abort()
+// ScopedAuthMiddleware accepts signed-in users as well as API clients that
+// were granted scope, either through an X-API-Key header or a client
+// credentials access token. Clients act as the key's owner without any
+// role.
+func ScopedAuthMiddleware(authService *services.AuthService, apiKeyService *services.APIKeyService, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if apiKey := c.GetHeader("X-API-Key"); apiKey != "" {
+			key, err := apiKeyService.Authenticate(apiKey, c.ClientIP())
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+				c.Abort()
+				return
+			}
+			if !key.HasScope(scope) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "API key lacks scope " + scope})
+				c.Abort()
+				return
+			}
+
+			c.Set("userID", key.UserID)
+			c.Next()
+			return
+		}
+
+		claims, ok := bearerClaims(c, authService)
+		if !ok {
+			return
+		}
+
+		if claims.ClientID != "" {
+			if err := apiKeyService.CheckClientToken(claims, c.ClientIP()); err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+				c.Abort()
+				return
+			}
+			if !hasScope(claims.Scope, scope) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "token lacks scope " + scope})
+				c.Abort()
+				return
+			}
+
+			c.Set("userID", claims.UserID)
+			c.Next()
 			return
 		}
 
-		// Set user ID in context
 		c.Set("userID", claims.UserID)
+		c.Set("role", claims.Role)
+		c.Set("sessionID", claims.SessionID)
 		c.Next()
 	}
 }
 
+// bearerClaims validates the access token from the Authorization header.
+// On failure it aborts the request and returns false.
+func bearerClaims(c *gin.Context, authService *services.AuthService) (*services.Claims, bool) {
+	authHeader := c.GetHeader("Authorization")
+	if authHeader == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authorization header required"})
+		c.Abort()
+		return nil, false
+	}
+
+	// Extract token from "Bearer <token>"
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid authorization header format"})
+		c.Abort()
+		return nil, false
+	}
+
+	tokenString := parts[1]
+
+	// Validate token
+	claims, err := authService.ValidateAccessToken(tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		c.Abort()
+		return nil, false
+	}
+
+	return claims, true
+}
+
+func hasScope(granted, scope string) bool {
+	for _, s := range strings.Fields(granted) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireRole allows the request only for users having one of the roles.
+// It must run after AuthMiddleware.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+		c.Abort()
+	}
+}
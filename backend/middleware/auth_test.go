@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"sensory-navigator/models"
+)
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name       string
+		role       string
+		allowed    []string
+		wantStatus int
+	}{
+		{name: "admin on admin route", role: models.RoleAdmin, allowed: []string{models.RoleAdmin}, wantStatus: http.StatusOK},
+		{name: "moderator on admin route", role: models.RoleModerator, allowed: []string{models.RoleAdmin}, wantStatus: http.StatusForbidden},
+		{name: "moderator on moderation route", role: models.RoleModerator, allowed: []string{models.RoleModerator, models.RoleAdmin}, wantStatus: http.StatusOK},
+		{name: "user on moderation route", role: models.RoleUser, allowed: []string{models.RoleModerator, models.RoleAdmin}, wantStatus: http.StatusForbidden},
+		{name: "no role set", role: "", allowed: []string{models.RoleUser}, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) {
+				if tt.role != "" {
+					c.Set("role", tt.role)
+				}
+			}, RequireRole(tt.allowed...), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
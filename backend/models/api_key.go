@@ -0,0 +1,80 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Scopes that can be granted to API keys.
+const (
+	ScopeReviewsRead = "reviews:read"
+	ScopePlacesRead  = "places:read"
+)
+
+type APIKey struct {
+	ID         int64          `json:"id"`
+	UserID     int64          `json:"user_id"`
+	Name       string         `json:"name"`
+	KeyID      string         `json:"key_id"`
+	SecretHash string         `json:"-"`
+	Scopes     []string       `json:"scopes"`
+	ExpiresAt  sql.NullTime   `json:"expires_at,omitempty"`
+	RevokedAt  sql.NullTime   `json:"revoked_at,omitempty"`
+	LastUsedAt sql.NullTime   `json:"last_used_at,omitempty"`
+	LastUsedIP sql.NullString `json:"last_used_ip,omitempty"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+type APIKeyResponse struct {
+	ID         int64    `json:"id"`
+	Name       string   `json:"name"`
+	ClientID   string   `json:"client_id"`
+	Scopes     []string `json:"scopes"`
+	ExpiresAt  *string  `json:"expires_at,omitempty"`
+	LastUsedAt *string  `json:"last_used_at,omitempty"`
+	LastUsedIP *string  `json:"last_used_ip,omitempty"`
+	CreatedAt  string   `json:"created_at"`
+	// Key is only returned once, when the key is created
+	Key string `json:"key,omitempty"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Scopes []string `json:"scopes" binding:"required,min=1,dive,oneof=reviews:read places:read"`
+	// ExpiresInDays leaves the key valid until revoked when omitted
+	ExpiresInDays *int `json:"expires_in_days,omitempty" binding:"omitempty,min=1,max=3650"`
+}
+
+// HasScope reports whether the key grants scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *APIKey) ToResponse() APIKeyResponse {
+	resp := APIKeyResponse{
+		ID:        k.ID,
+		Name:      k.Name,
+		ClientID:  k.KeyID,
+		Scopes:    k.Scopes,
+		CreatedAt: k.CreatedAt.Format(time.RFC3339),
+	}
+
+	if k.ExpiresAt.Valid {
+		expiresAt := k.ExpiresAt.Time.Format(time.RFC3339)
+		resp.ExpiresAt = &expiresAt
+	}
+	if k.LastUsedAt.Valid {
+		lastUsed := k.LastUsedAt.Time.Format(time.RFC3339)
+		resp.LastUsedAt = &lastUsed
+	}
+	if k.LastUsedIP.Valid {
+		resp.LastUsedIP = &k.LastUsedIP.String
+	}
+
+	return resp
+}
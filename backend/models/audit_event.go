@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// Audit event types.
+const (
+	EventRegistered             = "registered"
+	EventLoginSucceeded         = "login_succeeded"
+	EventLoginFailed            = "login_failed"
+	EventLogout                 = "logout"
+	EventTokenRefreshed         = "token_refreshed"
+	EventRefreshTokenReuse      = "refresh_token_reuse"
+	EventPasswordResetRequested = "password_reset_requested"
+	EventPasswordReset          = "password_reset"
+	EventPasswordChanged        = "password_changed"
+	EventEmailChanged           = "email_changed"
+	EventEmailChangeUndone      = "email_change_undone"
+	EventMagicLinkRequested     = "magic_link_requested"
+	EventProfileUpdated         = "profile_updated"
+	EventSessionRevoked         = "session_revoked"
+
+	EventTwoFactorEnabled         = "two_factor_enabled"
+	EventTwoFactorDisabled        = "two_factor_disabled"
+	EventRecoveryCodesRegenerated = "recovery_codes_regenerated"
+
+	EventAccountDeletionScheduled = "account_deletion_scheduled"
+	EventAccountRestored          = "account_restored"
+
+	EventRoleChanged     = "role_changed"
+	EventSessionsRevoked = "sessions_revoked"
+)
+
+// AuditEvent records something that happened to an account. ActorID is who
+// did it and TargetID the account affected; either is nil when unknown,
+// e.g. for a failed login with an unregistered email.
+type AuditEvent struct {
+	ID        int64                  `json:"id"`
+	ActorID   *int64                 `json:"actor_id"`
+	TargetID  *int64                 `json:"target_id"`
+	EventType string                 `json:"event_type"`
+	IPAddress string                 `json:"ip_address,omitempty"`
+	UserAgent string                 `json:"user_agent,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// AuditEventFilter narrows an audit log query. Zero values match anything.
+type AuditEventFilter struct {
+	ActorID   int64
+	TargetID  int64
+	EventType string
+	IPAddress string
+	From      time.Time
+	To        time.Time
+	Limit     int
+	Offset    int
+}
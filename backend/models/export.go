@@ -0,0 +1,12 @@
+package models
+
+// UserDataExport bundles the personal data stored about a user.
+type UserDataExport struct {
+	ExportedAt     string              `json:"exported_at"`
+	Profile        UserResponse        `json:"profile"`
+	Reviews        []*ReviewResponse   `json:"reviews"`
+	Favorites      []*FavoriteResponse `json:"favorites"`
+	Settings       *UserSettings       `json:"settings"`
+	SensoryProfile UserSensoryProfile  `json:"sensory_profile"`
+	Sessions       []SessionResponse   `json:"sessions"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// UserIdentity links an account at an external identity provider to a user.
+type UserIdentity struct {
+	ID          int64          `json:"id"`
+	UserID      int64          `json:"user_id"`
+	Provider    string         `json:"provider"`
+	Subject     string         `json:"subject"`
+	Email       sql.NullString `json:"email,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	LastLoginAt sql.NullTime   `json:"last_login_at,omitempty"`
+}
+
+// OIDCState is a pending authorization request.
+type OIDCState struct {
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+}
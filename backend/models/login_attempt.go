@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// LoginAttempt counts recent failed logins for an email address or IP.
+type LoginAttempt struct {
+	Key           string    `json:"key"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+}
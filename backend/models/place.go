@@ -13,6 +13,9 @@ type Place struct {
 	Longitude sql.NullFloat64 `json:"longitude,omitempty"`
 	Category  sql.NullString `json:"category,omitempty"`
 	CreatedAt time.Time      `json:"created_at"`
+	// Review aggregates kept up to date by triggers on reviews
+	ReviewCount int             `json:"review_count"`
+	Rating      sql.NullFloat64 `json:"rating,omitempty"`
 }
 
 type PlaceResponse struct {
@@ -23,13 +26,19 @@ type PlaceResponse struct {
 	Longitude *float64 `json:"longitude,omitempty"`
 	Category  *string  `json:"category,omitempty"`
 	CreatedAt string   `json:"created_at"`
+	// ReviewCount counts every review, Rating is the mean overall rating
+	ReviewCount int      `json:"review_count"`
+	Rating      *float64 `json:"rating,omitempty"`
+	// Fit is set for users with a sensory profile
+	Fit *PlaceFit `json:"fit,omitempty"`
 }
 
 func (p *Place) ToResponse() PlaceResponse {
 	resp := PlaceResponse{
-		ID:        p.ID,
-		Name:      p.Name,
-		CreatedAt: p.CreatedAt.Format(time.RFC3339),
+		ID:          p.ID,
+		Name:        p.Name,
+		CreatedAt:   p.CreatedAt.Format(time.RFC3339),
+		ReviewCount: p.ReviewCount,
 	}
 
 	if p.Address.Valid {
@@ -44,7 +53,46 @@ func (p *Place) ToResponse() PlaceResponse {
 	if p.Category.Valid {
 		resp.Category = &p.Category.String
 	}
+	if p.Rating.Valid {
+		resp.Rating = &p.Rating.Float64
+	}
 
 	return resp
 }
 
+
+type CreatePlaceRequest struct {
+	Name      string   `json:"name" binding:"required,max=255"`
+	Address   *string  `json:"address" binding:"omitempty,max=500"`
+	Latitude  *float64 `json:"latitude" binding:"omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" binding:"omitempty,min=-180,max=180"`
+	Category  *string  `json:"category" binding:"omitempty,max=100"`
+}
+
+// UpdatePlaceRequest changes only the fields that are set.
+type UpdatePlaceRequest struct {
+	Name      *string  `json:"name" binding:"omitempty,min=1,max=255"`
+	Address   *string  `json:"address" binding:"omitempty,max=500"`
+	Latitude  *float64 `json:"latitude" binding:"omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" binding:"omitempty,min=-180,max=180"`
+	Category  *string  `json:"category" binding:"omitempty,max=100"`
+}
+
+// NearbyPlace is a place found around a point, with its great-circle
+// distance from it.
+type NearbyPlace struct {
+	Place
+	DistanceM float64
+}
+
+type NearbyPlaceResponse struct {
+	PlaceResponse
+	DistanceM float64 `json:"distance_m"`
+}
+
+func (p *NearbyPlace) ToResponse() NearbyPlaceResponse {
+	return NearbyPlaceResponse{
+		PlaceResponse: p.Place.ToResponse(),
+		DistanceM:     p.DistanceM,
+	}
+}
@@ -0,0 +1,93 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// Sort orders of the place search. Distance sorts nearest first, the
+// others best first.
+const (
+	PlaceSortFit         = "fit"
+	PlaceSortDistance    = "distance"
+	PlaceSortRating      = "rating"
+	PlaceSortReviewCount = "review_count"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// GeoCircle is the area within RadiusM meters of a point.
+type GeoCircle struct {
+	Lat     float64
+	Lon     float64
+	RadiusM float64
+}
+
+// RatingRange bounds the mean rating of a dimension; nil ends are open.
+type RatingRange struct {
+	Min *float64
+	Max *float64
+}
+
+// PlaceSearchFilter selects places by category, area, mean ratings per
+// dimension and number of reviews. Places without ratings of a bounded
+// dimension never match.
+type PlaceSearchFilter struct {
+	Category   string
+	Near       *GeoCircle
+	Ratings    map[string]RatingRange
+	MinReviews int
+	Sort       string
+	// UserID is whose sensory profile the fit sort uses
+	UserID int64
+	After  *PlaceSearchCursor
+	Limit  int
+}
+
+// PlaceSearchCursor is the position of the last place of a page: its sort
+// key and ID. It is handed to clients as an opaque string.
+type PlaceSearchCursor struct {
+	Sort  string  `json:"s"`
+	Value float64 `json:"v"`
+	ID    int64   `json:"id"`
+}
+
+func (c *PlaceSearchCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodePlaceSearchCursor(cursor string) (*PlaceSearchCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &PlaceSearchCursor{}
+	if err := json.Unmarshal(data, c); err != nil || c.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// PlaceSearchResult is a matched place with its distance, if the search
+// was limited to an area, and its sort key for the next cursor.
+type PlaceSearchResult struct {
+	Place
+	DistanceM sql.NullFloat64
+	SortValue float64
+}
+
+type PlaceSearchResponse struct {
+	PlaceResponse
+	DistanceM *float64 `json:"distance_m,omitempty"`
+}
+
+func (p *PlaceSearchResult) ToResponse() PlaceSearchResponse {
+	resp := PlaceSearchResponse{PlaceResponse: p.Place.ToResponse()}
+	if p.DistanceM.Valid {
+		resp.DistanceM = &p.DistanceM.Float64
+	}
+	return resp
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestPlaceSearchCursor(t *testing.T) {
+	want := PlaceSearchCursor{Sort: PlaceSortRating, Value: 4.25, ID: 17}
+	got, err := DecodePlaceSearchCursor(want.Encode())
+	if err != nil {
+		t.Fatalf("DecodePlaceSearchCursor() error = %v", err)
+	}
+	if *got != want {
+		t.Errorf("DecodePlaceSearchCursor() = %+v, want %+v", *got, want)
+	}
+}
+
+func TestDecodePlaceSearchCursorRejectsInvalid(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "not base64", cursor: "!!!"},
+		{name: "not JSON", cursor: encode("rating:4:1")},
+		{name: "missing ID", cursor: encode(`{"s":"rating","v":4}`)},
+		{name: "negative ID", cursor: encode(`{"s":"rating","v":4,"id":-1}`)},
+		{name: "value not a number", cursor: encode(`{"s":"rating","v":"4","id":1}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodePlaceSearchCursor(tt.cursor); err != ErrInvalidCursor {
+				t.Errorf("DecodePlaceSearchCursor(%q) error = %v, want %v", tt.cursor, err, ErrInvalidCursor)
+			}
+		})
+	}
+}
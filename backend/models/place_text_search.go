@@ -0,0 +1,30 @@
+package models
+
+// PlaceTextMatch is a place found by full-text search. Rank combines how
+// well the place itself and its best review match the query.
+type PlaceTextMatch struct {
+	Place
+	Rank float64
+}
+
+// ReviewSnippet is a fragment of a review matching a search query. Snippet
+// is HTML-escaped text with the matching words wrapped in <mark>.
+type ReviewSnippet struct {
+	ReviewID int64  `json:"review_id"`
+	PlaceID  int64  `json:"-"`
+	Snippet  string `json:"snippet"`
+}
+
+type PlaceTextMatchResponse struct {
+	PlaceResponse
+	Rank     float64          `json:"rank"`
+	Snippets []*ReviewSnippet `json:"snippets"`
+}
+
+func (m *PlaceTextMatch) ToResponse() PlaceTextMatchResponse {
+	return PlaceTextMatchResponse{
+		PlaceResponse: m.Place.ToResponse(),
+		Rank:          m.Rank,
+		Snippets:      []*ReviewSnippet{},
+	}
+}
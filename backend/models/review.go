@@ -5,6 +5,9 @@ import (
 	"time"
 )
 
+// FormerUserName is shown as the author of reviews kept by deleted users.
+const FormerUserName = "former user"
+
 type Review struct {
 	ID                  int64          `json:"id"`
 	UserID              int64          `json:"user_id"`
@@ -16,6 +19,8 @@ type Review struct {
 	CrowdingRating      sql.NullInt32  `json:"crowding_rating,omitempty"`
 	AccessibilityRating sql.NullInt32  `json:"accessibility_rating,omitempty"`
 	OverallRating       sql.NullFloat64 `json:"overall_rating,omitempty"`
+	VisitedAt           sql.NullTime   `json:"visited_at,omitempty"`
+	VisitHourOfWeek     sql.NullInt32  `json:"visit_hour_of_week,omitempty"`
 	CreatedAt           time.Time      `json:"created_at"`
 	UpdatedAt           time.Time      `json:"updated_at"`
 }
@@ -31,6 +36,9 @@ type ReviewResponse struct {
 	CrowdingRating      *int     `json:"crowding_rating,omitempty"`
 	AccessibilityRating *int     `json:"accessibility_rating,omitempty"`
 	OverallRating       *float64 `json:"overall_rating,omitempty"`
+	VisitedAt           *string  `json:"visited_at,omitempty"`
+	VisitDayOfWeek      *int     `json:"visit_day_of_week,omitempty"`
+	VisitHour           *int     `json:"visit_hour,omitempty"`
 	CreatedAt           string   `json:"created_at"`
 	UpdatedAt           string   `json:"updated_at"`
 	Username            string   `json:"username,omitempty"`
@@ -45,6 +53,7 @@ type CreateReviewRequest struct {
 	CrowdingRating      *int     `json:"crowding_rating,omitempty" binding:"omitempty,min=1,max=5"`
 	AccessibilityRating *int     `json:"accessibility_rating,omitempty" binding:"omitempty,min=1,max=5"`
 	OverallRating       *float64 `json:"overall_rating,omitempty" binding:"omitempty,min=1,max=5"`
+	VisitTime
 }
 
 type UpdateReviewRequest struct {
@@ -55,6 +64,7 @@ type UpdateReviewRequest struct {
 	CrowdingRating      *int     `json:"crowding_rating,omitempty" binding:"omitempty,min=1,max=5"`
 	AccessibilityRating *int     `json:"accessibility_rating,omitempty" binding:"omitempty,min=1,max=5"`
 	OverallRating       *float64 `json:"overall_rating,omitempty" binding:"omitempty,min=1,max=5"`
+	VisitTime
 }
 
 func (r *Review) ToResponse() ReviewResponse {
@@ -92,6 +102,15 @@ func (r *Review) ToResponse() ReviewResponse {
 	if r.OverallRating.Valid {
 		resp.OverallRating = &r.OverallRating.Float64
 	}
+	if r.VisitedAt.Valid {
+		val := r.VisitedAt.Time.Format(VisitedAtLayout)
+		resp.VisitedAt = &val
+	}
+	if r.VisitHourOfWeek.Valid {
+		day, hour := SplitHourOfWeek(int(r.VisitHourOfWeek.Int32))
+		resp.VisitDayOfWeek = &day
+		resp.VisitHour = &hour
+	}
 
 	return resp
 }
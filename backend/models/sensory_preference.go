@@ -0,0 +1,90 @@
+package models
+
+import "time"
+
+// SensoryPreference is how much of one dimension a user can bear and how
+// much it matters to them. Ratings measure intensity, so Tolerance is the
+// highest acceptable rating, except for accessibility, where higher is
+// better and Tolerance is the lowest acceptable one.
+type SensoryPreference struct {
+	UserID    int64
+	Dimension string
+	Tolerance int
+	Weight    int
+	UpdatedAt time.Time
+}
+
+// HigherIsBetter reports whether higher ratings of the dimension are
+// welcome rather than more intense.
+func (p *SensoryPreference) HigherIsBetter() bool {
+	return p.Dimension == DimensionAccessibility
+}
+
+// Accepts reports whether a rating is within the user's tolerance.
+func (p *SensoryPreference) Accepts(rating int) bool {
+	if p.HigherIsBetter() {
+		return rating >= p.Tolerance
+	}
+	return rating <= p.Tolerance
+}
+
+type SensoryPreferenceSetting struct {
+	Tolerance int `json:"tolerance" binding:"required,min=1,max=5"`
+	Weight    int `json:"weight" binding:"required,min=1,max=5"`
+}
+
+// UserSensoryProfile lists the dimensions a user has set; the others do
+// not count in fit scores.
+type UserSensoryProfile struct {
+	Dimensions map[string]SensoryPreferenceSetting `json:"dimensions"`
+}
+
+// UpdateUserSensoryProfileRequest replaces the whole profile. Leaving a
+// dimension out clears it.
+type UpdateUserSensoryProfileRequest struct {
+	Dimensions map[string]SensoryPreferenceSetting `json:"dimensions" binding:"required,dive,keys,oneof=sensory lighting sound_level crowding accessibility,endkeys,required"`
+}
+
+func NewUserSensoryProfile(prefs []*SensoryPreference) UserSensoryProfile {
+	profile := UserSensoryProfile{Dimensions: make(map[string]SensoryPreferenceSetting, len(prefs))}
+	for _, p := range prefs {
+		profile.Dimensions[p.Dimension] = SensoryPreferenceSetting{Tolerance: p.Tolerance, Weight: p.Weight}
+	}
+	return profile
+}
+
+// Codes of the place fit flags, stable for clients to translate.
+const (
+	FitTooIntense    = "too_intense"
+	FitTooBright     = "too_bright"
+	FitTooLoud       = "too_loud"
+	FitTooCrowded    = "too_crowded"
+	FitNotAccessible = "not_accessible_enough"
+)
+
+// PlaceFit scores a place against a user's sensory profile. Score is 0-100,
+// nil if no reviews rate the dimensions the user set.
+type PlaceFit struct {
+	Score       *int           `json:"score"`
+	Flags       []PlaceFitFlag `json:"flags"`
+	Driver      string         `json:"driver,omitempty"`
+	Explanation string         `json:"explanation"`
+	Dimensions  []DimensionFit `json:"dimensions"`
+}
+
+// PlaceFitFlag warns that a place is likely beyond the user's tolerance.
+type PlaceFitFlag struct {
+	Dimension string `json:"dimension"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+}
+
+// DimensionFit is the share of a place's ratings of one dimension within
+// the user's tolerance, nil if there are none.
+type DimensionFit struct {
+	Dimension   string   `json:"dimension"`
+	Tolerance   int      `json:"tolerance"`
+	Weight      int      `json:"weight"`
+	RatingCount int      `json:"rating_count"`
+	WithinShare *float64 `json:"within_share"`
+}
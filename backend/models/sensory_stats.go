@@ -0,0 +1,147 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Sensory dimensions rated in reviews, named as in place_sensory_stats.
+const (
+	DimensionSensory       = "sensory"
+	DimensionLighting      = "lighting"
+	DimensionSoundLevel    = "sound_level"
+	DimensionCrowding      = "crowding"
+	DimensionAccessibility = "accessibility"
+)
+
+// SensoryDimensions lists the dimensions in display order.
+var SensoryDimensions = []string{
+	DimensionSensory,
+	DimensionLighting,
+	DimensionSoundLevel,
+	DimensionCrowding,
+	DimensionAccessibility,
+}
+
+// SensoryStats aggregates the ratings of one dimension of a place.
+// Histogram[n-1] counts ratings of n.
+type SensoryStats struct {
+	PlaceID    int64
+	Dimension  string
+	Count      int
+	Sum        int64
+	SumSquares int64
+	Histogram  []int64
+	UpdatedAt  time.Time
+}
+
+func (s *SensoryStats) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.Sum) / float64(s.Count)
+}
+
+// Variance is the population variance of the ratings.
+func (s *SensoryStats) Variance() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	mean := s.Mean()
+	// Clamp rounding error around zero when every rating is the same
+	return math.Max(0, float64(s.SumSquares)/float64(s.Count)-mean*mean)
+}
+
+type SensoryStatsResponse struct {
+	Count     int      `json:"count"`
+	Mean      *float64 `json:"mean"`
+	Variance  *float64 `json:"variance"`
+	Histogram []int64  `json:"histogram"`
+}
+
+// ToResponse reports a mean and variance only for rated dimensions.
+func (s *SensoryStats) ToResponse() SensoryStatsResponse {
+	resp := SensoryStatsResponse{
+		Count:     s.Count,
+		Histogram: s.Histogram,
+	}
+	if s.Count > 0 {
+		mean, variance := s.Mean(), s.Variance()
+		resp.Mean = &mean
+		resp.Variance = &variance
+	}
+	return resp
+}
+
+// SensoryProfileResponse has an entry for every dimension, rated or not.
+type SensoryProfileResponse struct {
+	PlaceID     int64                           `json:"place_id"`
+	ReviewCount int                             `json:"review_count"`
+	Dimensions  map[string]SensoryStatsResponse `json:"dimensions"`
+}
+
+// NewSensoryProfileResponse fills in the dimensions missing from stats.
+func NewSensoryProfileResponse(placeID int64, reviewCount int, stats []*SensoryStats) SensoryProfileResponse {
+	return SensoryProfileResponse{
+		PlaceID:     placeID,
+		ReviewCount: reviewCount,
+		Dimensions:  newDimensionsResponse(stats),
+	}
+}
+
+func newDimensionsResponse(stats []*SensoryStats) map[string]SensoryStatsResponse {
+	byDimension := make(map[string]*SensoryStats, len(stats))
+	for _, s := range stats {
+		byDimension[s.Dimension] = s
+	}
+
+	dimensions := make(map[string]SensoryStatsResponse, len(SensoryDimensions))
+	for _, dimension := range SensoryDimensions {
+		s, ok := byDimension[dimension]
+		if !ok {
+			s = &SensoryStats{Dimension: dimension, Histogram: make([]int64, 5)}
+		}
+		dimensions[dimension] = s.ToResponse()
+	}
+	return dimensions
+}
+
+// SensoryTimeBucket aggregates the reviews of visits in one hour of the
+// week, see JoinHourOfWeek.
+type SensoryTimeBucket struct {
+	HourOfWeek  int
+	ReviewCount int
+	Stats       []*SensoryStats
+}
+
+type SensoryTimeBucketResponse struct {
+	HourOfWeek  int                             `json:"hour_of_week"`
+	DayOfWeek   int                             `json:"day_of_week"`
+	Hour        int                             `json:"hour"`
+	ReviewCount int                             `json:"review_count"`
+	Dimensions  map[string]SensoryStatsResponse `json:"dimensions"`
+}
+
+func (b *SensoryTimeBucket) ToResponse() SensoryTimeBucketResponse {
+	day, hour := SplitHourOfWeek(b.HourOfWeek)
+	return SensoryTimeBucketResponse{
+		HourOfWeek:  b.HourOfWeek,
+		DayOfWeek:   day,
+		Hour:        hour,
+		ReviewCount: b.ReviewCount,
+		Dimensions:  newDimensionsResponse(b.Stats),
+	}
+}
+
+// BestVisitTime recommends visiting around an hour of the week. The
+// figures cover the reviews of that hour and the ones next to it. Load is
+// the mean rating of the intensity dimensions, lower is calmer; FitScore
+// is set for users with a sensory profile.
+type BestVisitTime struct {
+	HourOfWeek  int      `json:"hour_of_week"`
+	DayOfWeek   int      `json:"day_of_week"`
+	Hour        int      `json:"hour"`
+	ReviewCount int      `json:"review_count"`
+	Load        *float64 `json:"load"`
+	FitScore    *int     `json:"fit_score,omitempty"`
+}
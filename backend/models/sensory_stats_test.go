@@ -0,0 +1,73 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+// stats builds the aggregates the review trigger keeps for ratings.
+func stats(dimension string, ratings ...int) *SensoryStats {
+	s := &SensoryStats{Dimension: dimension, Histogram: make([]int64, 5)}
+	for _, r := range ratings {
+		s.Count++
+		s.Sum += int64(r)
+		s.SumSquares += int64(r * r)
+		s.Histogram[r-1]++
+	}
+	return s
+}
+
+func TestSensoryStatsMeanAndVariance(t *testing.T) {
+	tests := []struct {
+		name         string
+		stats        *SensoryStats
+		wantMean     float64
+		wantVariance float64
+	}{
+		{name: "no ratings", stats: stats(DimensionLighting), wantMean: 0, wantVariance: 0},
+		{name: "single rating", stats: stats(DimensionLighting, 4), wantMean: 4, wantVariance: 0},
+		{name: "all the same", stats: stats(DimensionLighting, 3, 3, 3), wantMean: 3, wantVariance: 0},
+		{name: "spread", stats: stats(DimensionLighting, 1, 5), wantMean: 3, wantVariance: 4},
+		{name: "uneven", stats: stats(DimensionLighting, 1, 2, 2, 5), wantMean: 2.5, wantVariance: 2.25},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.stats.Mean(); math.Abs(got-tt.wantMean) > 1e-9 {
+				t.Errorf("Mean() = %v, want %v", got, tt.wantMean)
+			}
+			if got := tt.stats.Variance(); math.Abs(got-tt.wantVariance) > 1e-9 {
+				t.Errorf("Variance() = %v, want %v", got, tt.wantVariance)
+			}
+		})
+	}
+}
+
+func TestNewSensoryProfileResponse(t *testing.T) {
+	resp := NewSensoryProfileResponse(7, 2, []*SensoryStats{
+		stats(DimensionSoundLevel, 2, 4),
+	})
+
+	if resp.PlaceID != 7 || resp.ReviewCount != 2 {
+		t.Errorf("place %d with %d reviews, want place 7 with 2", resp.PlaceID, resp.ReviewCount)
+	}
+	if len(resp.Dimensions) != len(SensoryDimensions) {
+		t.Fatalf("%d dimensions, want %d", len(resp.Dimensions), len(SensoryDimensions))
+	}
+
+	for _, dimension := range SensoryDimensions {
+		got := resp.Dimensions[dimension]
+		if len(got.Histogram) != 5 {
+			t.Errorf("%s: histogram has %d buckets, want 5", dimension, len(got.Histogram))
+		}
+		if dimension != DimensionSoundLevel {
+			if got.Count != 0 || got.Mean != nil || got.Variance != nil {
+				t.Errorf("%s: unrated dimension = %+v, want no count, mean or variance", dimension, got)
+			}
+			continue
+		}
+		if got.Count != 2 || got.Mean == nil || *got.Mean != 3 || got.Variance == nil || *got.Variance != 1 {
+			t.Errorf("%s = %+v, want 2 ratings with mean 3 and variance 1", dimension, got)
+		}
+	}
+}
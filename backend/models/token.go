@@ -0,0 +1,63 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type RefreshToken struct {
+	ID        int64     `json:"id"`
+	UserID    int64     `json:"user_id"`
+	FamilyID  string    `json:"family_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Revoked   bool      `json:"revoked"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ClientInfo describes the device a token pair is issued to.
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// Session is the active refresh token of a refresh token family.
+type Session struct {
+	ID         int64          `json:"id"`
+	FamilyID   string         `json:"-"`
+	UserAgent  sql.NullString `json:"user_agent,omitempty"`
+	IPAddress  sql.NullString `json:"ip_address,omitempty"`
+	StartedAt  time.Time      `json:"started_at"`
+	LastUsedAt sql.NullTime   `json:"last_used_at,omitempty"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+}
+
+type SessionResponse struct {
+	ID         int64   `json:"id"`
+	UserAgent  *string `json:"user_agent,omitempty"`
+	IPAddress  *string `json:"ip_address,omitempty"`
+	StartedAt  string  `json:"started_at"`
+	LastUsedAt *string `json:"last_used_at,omitempty"`
+	ExpiresAt  string  `json:"expires_at"`
+	Current    bool    `json:"current"`
+}
+
+func (s *Session) ToResponse() SessionResponse {
+	resp := SessionResponse{
+		ID:        s.ID,
+		StartedAt: s.StartedAt.Format(time.RFC3339),
+		ExpiresAt: s.ExpiresAt.Format(time.RFC3339),
+	}
+
+	if s.UserAgent.Valid {
+		resp.UserAgent = &s.UserAgent.String
+	}
+	if s.IPAddress.Valid {
+		resp.IPAddress = &s.IPAddress.String
+	}
+	if s.LastUsedAt.Valid {
+		lastUsed := s.LastUsedAt.Time.Format(time.RFC3339)
+		resp.LastUsedAt = &lastUsed
+	}
+
+	return resp
+}
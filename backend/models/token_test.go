@@ -0,0 +1,74 @@
+package models
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestSessionToResponse(t *testing.T) {
+	started := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	lastUsed := started.Add(time.Hour)
+	expires := started.Add(7 * 24 * time.Hour)
+
+	tests := []struct {
+		name          string
+		session       Session
+		wantUserAgent *string
+		wantIPAddress *string
+		wantLastUsed  *string
+	}{
+		{
+			name:    "never refreshed, no client info",
+			session: Session{ID: 1, StartedAt: started, ExpiresAt: expires},
+		},
+		{
+			name: "all fields",
+			session: Session{
+				ID:         2,
+				UserAgent:  sql.NullString{String: "Firefox", Valid: true},
+				IPAddress:  sql.NullString{String: "192.0.2.1", Valid: true},
+				StartedAt:  started,
+				LastUsedAt: sql.NullTime{Time: lastUsed, Valid: true},
+				ExpiresAt:  expires,
+			},
+			wantUserAgent: stringPtr("Firefox"),
+			wantIPAddress: stringPtr("192.0.2.1"),
+			wantLastUsed:  stringPtr("2024-03-01T11:00:00Z"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := tt.session.ToResponse()
+
+			if resp.ID != tt.session.ID {
+				t.Errorf("ID = %d, want %d", resp.ID, tt.session.ID)
+			}
+			if resp.StartedAt != "2024-03-01T10:00:00Z" || resp.ExpiresAt != "2024-03-08T10:00:00Z" {
+				t.Errorf("StartedAt, ExpiresAt = %s, %s", resp.StartedAt, resp.ExpiresAt)
+			}
+			if resp.Current {
+				t.Error("Current is set by the handler, not ToResponse")
+			}
+			checkOptional(t, "UserAgent", resp.UserAgent, tt.wantUserAgent)
+			checkOptional(t, "IPAddress", resp.IPAddress, tt.wantIPAddress)
+			checkOptional(t, "LastUsedAt", resp.LastUsedAt, tt.wantLastUsed)
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func checkOptional(t *testing.T, field string, got, want *string) {
+	t.Helper()
+	switch {
+	case got == nil && want == nil:
+	case got == nil || want == nil:
+		t.Errorf("%s = %v, want %v", field, got, want)
+	case *got != *want:
+		t.Errorf("%s = %q, want %q", field, *got, *want)
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+type TOTPSecret struct {
+	UserID       int64        `json:"user_id"`
+	Secret       string       `json:"-"`
+	ConfirmedAt  sql.NullTime `json:"confirmed_at,omitempty"`
+	LastUsedStep int64        `json:"-"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
+
+type TwoFactorSetupResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type LoginTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	// Code is a TOTP code or one of the recovery codes
+	Code string `json:"code" binding:"required"`
+}
@@ -5,32 +5,64 @@ import (
 	"time"
 )
 
+// Roles a user can have. Moderators manage content of other users,
+// admins additionally manage the users themselves.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 type User struct {
-	ID           int64          `json:"id"`
-	Email        string         `json:"email"`
-	PasswordHash string         `json:"-"`
-	Username     string         `json:"username"`
-	AvatarURL    sql.NullString `json:"avatar_url,omitempty"`
-	BirthDate    sql.NullTime   `json:"birth_date,omitempty"`
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
+	ID                  int64          `json:"id"`
+	Email               string         `json:"email"`
+	PasswordHash        string         `json:"-"`
+	Username            string         `json:"username"`
+	AvatarURL           sql.NullString `json:"avatar_url,omitempty"`
+	BirthDate           sql.NullTime   `json:"birth_date,omitempty"`
+	EmailVerifiedAt     sql.NullTime   `json:"email_verified_at,omitempty"`
+	Role                string         `json:"role"`
+	DeletionScheduledAt sql.NullTime   `json:"deletion_scheduled_at,omitempty"`
+	TokenGeneration     int64          `json:"-"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+}
+
+// UserSettings are the preferences stored in user_settings.
+type UserSettings struct {
+	NotificationsEnabled bool   `json:"notifications_enabled"`
+	EmailNotifications   bool   `json:"email_notifications"`
+	Language             string `json:"language"`
+	Theme                string `json:"theme"`
 }
 
 type UserResponse struct {
-	ID        int64   `json:"id"`
-	Email     string  `json:"email"`
-	Username  string  `json:"username"`
-	AvatarURL *string `json:"avatar_url,omitempty"`
-	BirthDate *string `json:"birth_date,omitempty"`
-	CreatedAt string  `json:"created_at"`
+	ID                  int64   `json:"id"`
+	Email               string  `json:"email"`
+	Username            string  `json:"username"`
+	AvatarURL           *string `json:"avatar_url,omitempty"`
+	BirthDate           *string `json:"birth_date,omitempty"`
+	EmailVerified       bool    `json:"email_verified"`
+	Role                string  `json:"role"`
+	DeletionScheduledAt *string `json:"deletion_scheduled_at,omitempty"`
+	CreatedAt           string  `json:"created_at"`
+}
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=user moderator admin"`
 }
 
 type RegisterRequest struct {
 	Email    string `json:"email" binding:"required,email"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
 	Username string `json:"username" binding:"required,min=2"`
 }
 
+// Accounts created through OIDC have no password. Password takes their
+// emailed reauthentication code instead, also when restoring the account,
+// as do the password fields of DisableTwoFactorRequest,
+// ChangePasswordRequest, ChangeEmailRequest and DeleteAccountRequest;
+// ChangePasswordRequest then sets the first password.
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
@@ -46,9 +78,55 @@ type ForgotPasswordRequest struct {
 	Email string `json:"email" binding:"required,email"`
 }
 
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	// BindDevice makes the link work only together with the returned
+	// device token
+	BindDevice bool `json:"bind_device"`
+}
+
+type VerifyMagicLinkRequest struct {
+	Token       string `json:"token" binding:"required"`
+	DeviceToken string `json:"device_token"`
+}
+
 type ResetPasswordRequest struct {
 	Token       string `json:"token" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=6"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail string `json:"new_email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+	// KeepReviews leaves the reviews as anonymous "former user" content
+	KeepReviews bool `json:"keep_reviews"`
+}
+
+type EmailChangeTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ReauthenticationRequest asks for a code that accounts without a password
+// send in place of it, see LoginRequest.
+type ReauthenticationRequest struct {
+	Email string `json:"email" binding:"required,email"`
 }
 
 type RefreshTokenRequest struct {
@@ -57,10 +135,12 @@ type RefreshTokenRequest struct {
 
 func (u *User) ToResponse() UserResponse {
 	resp := UserResponse{
-		ID:        u.ID,
-		Email:     u.Email,
-		Username:  u.Username,
-		CreatedAt: u.CreatedAt.Format(time.RFC3339),
+		ID:            u.ID,
+		Email:         u.Email,
+		Username:      u.Username,
+		EmailVerified: u.EmailVerifiedAt.Valid,
+		Role:          u.Role,
+		CreatedAt:     u.CreatedAt.Format(time.RFC3339),
 	}
 
 	if u.AvatarURL.Valid {
@@ -72,6 +152,20 @@ func (u *User) ToResponse() UserResponse {
 		resp.BirthDate = &dateStr
 	}
 
+	if u.DeletionScheduledAt.Valid {
+		deletionAt := u.DeletionScheduledAt.Time.Format(time.RFC3339)
+		resp.DeletionScheduledAt = &deletionAt
+	}
+
 	return resp
 }
 
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// CanModerate reports whether the role may edit and delete content
+// created by other users.
+func CanModerate(role string) bool {
+	return role == RoleModerator || role == RoleAdmin
+}
@@ -0,0 +1,22 @@
+package models
+
+import "testing"
+
+func TestCanModerate(t *testing.T) {
+	tests := []struct {
+		role string
+		want bool
+	}{
+		{RoleUser, false},
+		{RoleModerator, true},
+		{RoleAdmin, true},
+		{"", false},
+		{"superuser", false},
+	}
+
+	for _, tt := range tests {
+		if got := CanModerate(tt.role); got != tt.want {
+			t.Errorf("CanModerate(%q) = %v, want %v", tt.role, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// VisitedAtLayout formats visit times, which are local to the place and
+// have no time zone.
+const VisitedAtLayout = "2006-01-02T15:04:05"
+
+// LocalTime is a wall-clock time without a time zone, written as
+// VisitedAtLayout in requests as well as responses.
+type LocalTime struct {
+	time.Time
+}
+
+func (t LocalTime) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Format(VisitedAtLayout))
+}
+
+func (t *LocalTime) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	parsed, err := time.Parse(VisitedAtLayout, value)
+	if err != nil {
+		return errors.New("visited_at must be a local time like 2024-03-05T10:30:00")
+	}
+	t.Time = parsed
+	return nil
+}
+
+// Value stores the wall clock in a TIMESTAMP column.
+func (t LocalTime) Value() (driver.Value, error) {
+	return t.Time, nil
+}
+
+// HoursPerWeek is the number of hour-of-week buckets.
+const HoursPerWeek = 7 * 24
+
+var (
+	ErrVisitTimeConflict   = errors.New("set either visited_at or visit_day_of_week and visit_hour, not both")
+	ErrVisitTimeIncomplete = errors.New("visit_day_of_week and visit_hour must be set together")
+	ErrVisitTimeInFuture   = errors.New("visited_at must not be in the future")
+)
+
+// VisitTime is when a reviewer was at the place: either the exact local
+// time, or only the day of the week (1 is Monday) and hour.
+type VisitTime struct {
+	VisitedAt      *LocalTime `json:"visited_at,omitempty"`
+	VisitDayOfWeek *int       `json:"visit_day_of_week,omitempty" binding:"omitempty,min=1,max=7"`
+	VisitHour      *int       `json:"visit_hour,omitempty" binding:"omitempty,min=0,max=23"`
+}
+
+// HourOfWeek returns the hour-of-week bucket of the visit, or nil if no
+// time was given. visited_at is the wall clock at the place. Its zone is
+// unknown, so the future check reads it in the server's time zone and
+// compares it with the server clock.
+func (v *VisitTime) HourOfWeek() (*int, error) {
+	if v.VisitedAt != nil {
+		if v.VisitDayOfWeek != nil || v.VisitHour != nil {
+			return nil, ErrVisitTimeConflict
+		}
+		visited := v.VisitedAt.Time
+		serverLocal := time.Date(visited.Year(), visited.Month(), visited.Day(),
+			visited.Hour(), visited.Minute(), visited.Second(), visited.Nanosecond(), time.Local)
+		if serverLocal.After(time.Now()) {
+			return nil, ErrVisitTimeInFuture
+		}
+		day := int(visited.Weekday())
+		if day == 0 {
+			day = 7
+		}
+		hourOfWeek := JoinHourOfWeek(day, visited.Hour())
+		return &hourOfWeek, nil
+	}
+
+	if (v.VisitDayOfWeek == nil) != (v.VisitHour == nil) {
+		return nil, ErrVisitTimeIncomplete
+	}
+	if v.VisitDayOfWeek == nil {
+		return nil, nil
+	}
+	hourOfWeek := JoinHourOfWeek(*v.VisitDayOfWeek, *v.VisitHour)
+	return &hourOfWeek, nil
+}
+
+// JoinHourOfWeek numbers the hours of the week from 0, Monday 00:00-00:59,
+// to 167, Sunday 23:00-23:59. dayOfWeek is 1 for Monday to 7 for Sunday.
+func JoinHourOfWeek(dayOfWeek, hour int) int {
+	return (dayOfWeek-1)*24 + hour
+}
+
+// SplitHourOfWeek is the inverse of JoinHourOfWeek.
+func SplitHourOfWeek(hourOfWeek int) (dayOfWeek, hour int) {
+	return hourOfWeek/24 + 1, hourOfWeek % 24
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestVisitTimeJSONRoundTrip(t *testing.T) {
+	var v VisitTime
+	if err := json.Unmarshal([]byte(`{"visited_at":"2024-03-05T10:30:00"}`), &v); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if v.VisitedAt == nil || v.VisitedAt.Hour() != 10 || v.VisitedAt.Minute() != 30 {
+		t.Fatalf("VisitedAt = %v, want 10:30", v.VisitedAt)
+	}
+
+	// Responses use the same layout the request was written in
+	review := Review{}
+	review.VisitedAt.Time, review.VisitedAt.Valid = v.VisitedAt.Time, true
+	if got := *review.ToResponse().VisitedAt; got != "2024-03-05T10:30:00" {
+		t.Errorf("response visited_at = %q, want %q", got, "2024-03-05T10:30:00")
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(data) != `{"visited_at":"2024-03-05T10:30:00"}` {
+		t.Errorf("Marshal() = %s", data)
+	}
+}
+
+func TestVisitTimeRejectsOtherFormats(t *testing.T) {
+	for _, value := range []string{`"2024-03-05T10:30:00+03:00"`, `"2024-03-05 10:30"`, `"2024-03-05"`, `1709634600`} {
+		var v VisitTime
+		if err := json.Unmarshal([]byte(`{"visited_at":`+value+`}`), &v); err == nil {
+			t.Errorf("Unmarshal(%s) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestVisitTimeHourOfWeek(t *testing.T) {
+	local := func(value string) *LocalTime {
+		parsed, err := time.Parse(VisitedAtLayout, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &LocalTime{parsed}
+	}
+	intPtr := func(v int) *int { return &v }
+	tomorrow := &LocalTime{time.Now().Add(24 * time.Hour)}
+
+	tests := []struct {
+		name    string
+		visit   VisitTime
+		want    *int
+		wantErr error
+	}{
+		{name: "none", visit: VisitTime{}},
+		{name: "monday morning", visit: VisitTime{VisitedAt: local("2024-03-04T00:15:00")}, want: intPtr(0)},
+		{name: "tuesday 10:30", visit: VisitTime{VisitedAt: local("2024-03-05T10:30:00")}, want: intPtr(34)},
+		{name: "sunday night", visit: VisitTime{VisitedAt: local("2024-03-10T23:59:59")}, want: intPtr(167)},
+		{name: "day and hour", visit: VisitTime{VisitDayOfWeek: intPtr(7), VisitHour: intPtr(23)}, want: intPtr(167)},
+		{name: "day without hour", visit: VisitTime{VisitDayOfWeek: intPtr(1)}, wantErr: ErrVisitTimeIncomplete},
+		{name: "hour without day", visit: VisitTime{VisitHour: intPtr(8)}, wantErr: ErrVisitTimeIncomplete},
+		{name: "both forms", visit: VisitTime{VisitedAt: local("2024-03-05T10:30:00"), VisitHour: intPtr(8)}, wantErr: ErrVisitTimeConflict},
+		{name: "future", visit: VisitTime{VisitedAt: tomorrow}, wantErr: ErrVisitTimeInFuture},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.visit.HourOfWeek()
+			if err != tt.wantErr {
+				t.Fatalf("HourOfWeek() error = %v, want %v", err, tt.wantErr)
+			}
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("HourOfWeek() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+
+	"sensory-navigator/models"
+)
+
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+const apiKeyColumns = `id, user_id, name, key_id, secret_hash, scopes, expires_at, revoked_at,
+	last_used_at, last_used_ip, created_at`
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	err := row.Scan(
+		&key.ID, &key.UserID, &key.Name, &key.KeyID, &key.SecretHash, pq.Array(&key.Scopes),
+		&key.ExpiresAt, &key.RevokedAt, &key.LastUsedAt, &key.LastUsedIP, &key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Create stores a new key; only the digest of secret is kept.
+func (r *APIKeyRepository) Create(userID int64, name, keyID, secret string, scopes []string, expiresAt *time.Time) (*models.APIKey, error) {
+	return scanAPIKey(r.db.QueryRow(`
+		INSERT INTO api_keys (user_id, name, key_id, secret_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+apiKeyColumns, userID, name, keyID, hashToken(secret), pq.Array(scopes), expiresAt))
+}
+
+// FindActive returns a key that is neither revoked nor expired. Keys of
+// accounts scheduled for deletion are refused as well.
+func (r *APIKeyRepository) FindActive(keyID string) (*models.APIKey, error) {
+	return scanAPIKey(r.db.QueryRow(`
+		SELECT `+apiKeyColumns+` FROM api_keys
+		WHERE key_id = $1 AND revoked_at IS NULL
+			AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP)
+			AND NOT EXISTS (
+				SELECT 1 FROM users
+				WHERE users.id = api_keys.user_id AND users.deletion_scheduled_at IS NOT NULL
+			)
+	`, keyID))
+}
+
+// FindByUserID lists the user's keys that have not been revoked.
+func (r *APIKeyRepository) FindByUserID(userID int64) ([]*models.APIKey, error) {
+	rows, err := r.db.Query(`
+		SELECT `+apiKeyColumns+` FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (r *APIKeyRepository) CountActive(userID int64) (int, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID).Scan(&count)
+	return count, err
+}
+
+// Revoke disables one of the user's keys. It returns sql.ErrNoRows if the
+// key does not exist or belongs to someone else.
+func (r *APIKeyRepository) Revoke(userID, id int64) error {
+	result, err := r.db.Exec(`
+		UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, id, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevokeAllForUser disables every key of the user, e.g. once the account
+// may have been taken over.
+func (r *APIKeyRepository) RevokeAllForUser(userID int64) error {
+	_, err := r.db.Exec(`
+		UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	return err
+}
+
+// TouchLastUsed records a use of the key. Updates are limited to one per
+// minute so that busy scripts do not write on every request.
+func (r *APIKeyRepository) TouchLastUsed(id int64, ip string) error {
+	_, err := r.db.Exec(`
+		UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP, last_used_ip = $1
+		WHERE id = $2 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')
+	`, ip, id)
+	return err
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"sensory-navigator/models"
+)
+
+const auditEventColumns = `id, actor_id, target_id, event_type, COALESCE(ip_address, ''), COALESCE(user_agent, ''), details, created_at`
+
+// AuditEventRepository appends to and reads the audit log. It never
+// changes recorded events; the table rejects that as well.
+type AuditEventRepository struct {
+	db *sql.DB
+}
+
+func NewAuditEventRepository(db *sql.DB) *AuditEventRepository {
+	return &AuditEventRepository{db: db}
+}
+
+func (r *AuditEventRepository) Record(event *models.AuditEvent) error {
+	var details []byte
+	if len(event.Details) > 0 {
+		var err error
+		if details, err = json.Marshal(event.Details); err != nil {
+			return err
+		}
+	}
+
+	_, err := r.db.Exec(`
+		INSERT INTO audit_events (actor_id, target_id, event_type, ip_address, user_agent, details)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6)
+	`, event.ActorID, event.TargetID, event.EventType, event.IPAddress, event.UserAgent, details)
+	return err
+}
+
+// Find lists events matching the filter, newest first, along with the
+// number of matching events.
+func (r *AuditEventRepository) Find(filter *models.AuditEventFilter) ([]*models.AuditEvent, int, error) {
+	var conditions []string
+	var args []interface{}
+	where := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID != 0 {
+		where("actor_id = $%d", filter.ActorID)
+	}
+	if filter.TargetID != 0 {
+		where("target_id = $%d", filter.TargetID)
+	}
+	if filter.EventType != "" {
+		where("event_type = $%d", filter.EventType)
+	}
+	if filter.IPAddress != "" {
+		where("ip_address = $%d", filter.IPAddress)
+	}
+	if !filter.From.IsZero() {
+		where("created_at >= $%d", filter.From)
+	}
+	if !filter.To.IsZero() {
+		where("created_at < $%d", filter.To)
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM audit_events `+whereClause, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, filter.Limit, filter.Offset)
+	rows, err := r.db.Query(fmt.Sprintf(`
+		SELECT `+auditEventColumns+` FROM audit_events
+		%s
+		ORDER BY created_at DESC, id DESC
+		LIMIT $%d OFFSET $%d
+	`, whereClause, len(args)-1, len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	events := []*models.AuditEvent{}
+	for rows.Next() {
+		event := &models.AuditEvent{}
+		var details []byte
+		if err := rows.Scan(&event.ID, &event.ActorID, &event.TargetID, &event.EventType,
+			&event.IPAddress, &event.UserAgent, &details, &event.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if len(details) > 0 {
+			if err := json.Unmarshal(details, &event.Details); err != nil {
+				return nil, 0, err
+			}
+		}
+		events = append(events, event)
+	}
+
+	return events, total, rows.Err()
+}
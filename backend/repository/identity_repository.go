@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"sensory-navigator/models"
+)
+
+type IdentityRepository struct {
+	db *sql.DB
+}
+
+func NewIdentityRepository(db *sql.DB) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+func (r *IdentityRepository) FindByProviderSubject(provider, subject string) (*models.UserIdentity, error) {
+	identity := &models.UserIdentity{}
+	err := r.db.QueryRow(`
+		SELECT id, user_id, provider, subject, email, created_at, last_login_at
+		FROM user_identities WHERE provider = $1 AND subject = $2
+	`, provider, subject).Scan(
+		&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject,
+		&identity.Email, &identity.CreatedAt, &identity.LastLoginAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+func (r *IdentityRepository) Create(userID int64, provider, subject, email string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO user_identities (user_id, provider, subject, email, last_login_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), CURRENT_TIMESTAMP)
+	`, userID, provider, subject, email)
+	return err
+}
+
+func (r *IdentityRepository) TouchLastLogin(id int64) error {
+	_, err := r.db.Exec(`
+		UPDATE user_identities SET last_login_at = CURRENT_TIMESTAMP WHERE id = $1
+	`, id)
+	return err
+}
+
+func (r *IdentityRepository) SaveState(state string, s *models.OIDCState, expiresAt time.Time) error {
+	_, err := r.db.Exec(`
+		INSERT INTO oidc_states (state_hash, provider, code_verifier, nonce, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, hashToken(state), s.Provider, s.CodeVerifier, s.Nonce, expiresAt)
+	return err
+}
+
+// ConsumeState deletes and returns a pending authorization request, so each
+// state can be redeemed only once. Expired states are purged on the way.
+func (r *IdentityRepository) ConsumeState(state string) (*models.OIDCState, error) {
+	if _, err := r.db.Exec(`DELETE FROM oidc_states WHERE expires_at <= CURRENT_TIMESTAMP`); err != nil {
+		return nil, err
+	}
+
+	s := &models.OIDCState{}
+	err := r.db.QueryRow(`
+		DELETE FROM oidc_states WHERE state_hash = $1
+		RETURNING provider, code_verifier, nonce
+	`, hashToken(state)).Scan(&s.Provider, &s.CodeVerifier, &s.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"sensory-navigator/models"
+)
+
+// LoginAttemptRepository keeps failed login counters in Postgres so that
+// they are shared by all backend instances.
+type LoginAttemptRepository struct {
+	db *sql.DB
+}
+
+func NewLoginAttemptRepository(db *sql.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{db: db}
+}
+
+func (r *LoginAttemptRepository) Get(key string) (*models.LoginAttempt, error) {
+	attempt := &models.LoginAttempt{Key: key}
+	err := r.db.QueryRow(`
+		SELECT failures, last_failure_at FROM login_attempts WHERE key = $1
+	`, key).Scan(&attempt.Failures, &attempt.LastFailureAt)
+	if err == sql.ErrNoRows {
+		return attempt, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+func (r *LoginAttemptRepository) RecordFailure(key string, window time.Duration) (*models.LoginAttempt, error) {
+	attempt := &models.LoginAttempt{Key: key}
+	err := r.db.QueryRow(`
+		INSERT INTO login_attempts (key, failures, last_failure_at)
+		VALUES ($1, 1, CURRENT_TIMESTAMP)
+		ON CONFLICT (key) DO UPDATE SET
+			failures = CASE
+				WHEN login_attempts.last_failure_at < CURRENT_TIMESTAMP - make_interval(secs => $2) THEN 1
+				ELSE login_attempts.failures + 1
+			END,
+			last_failure_at = CURRENT_TIMESTAMP
+		RETURNING failures, last_failure_at
+	`, key, window.Seconds()).Scan(&attempt.Failures, &attempt.LastFailureAt)
+	if err != nil {
+		return nil, err
+	}
+	return attempt, nil
+}
+
+func (r *LoginAttemptRepository) Release(key string) error {
+	_, err := r.db.Exec(`
+		UPDATE login_attempts SET failures = failures - 1 WHERE key = $1 AND failures > 0
+	`, key)
+	return err
+}
+
+func (r *LoginAttemptRepository) Reset(key string) error {
+	_, err := r.db.Exec(`DELETE FROM login_attempts WHERE key = $1`, key)
+	return err
+}
@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+
+	"sensory-navigator/models"
+)
+
+// earthRadiusM is the mean Earth radius used for great-circle distances.
+const earthRadiusM = 6371008.8
+
+// NearbyPlaceFinder finds places within radiusM meters of a point,
+// nearest first. PlaceRepository implements it with plain SQL,
+// PostGISPlaceRepository with a spatial index.
+type NearbyPlaceFinder interface {
+	FindNearby(lat, lon, radiusM float64, limit int) ([]*models.NearbyPlace, error)
+}
+
+// boundingBox returns the latitude and longitude ranges around a point
+// that contain every point within radiusM meters. Near the poles or across
+// the antimeridian the longitude range does not fit in one interval;
+// anyLon is then set and the latitude range alone bounds the area.
+func boundingBox(lat, lon, radiusM float64) (minLat, maxLat, minLon, maxLon float64, anyLon bool) {
+	deltaLat := radiusM / earthRadiusM * 180 / math.Pi
+	minLat, maxLat = lat-deltaLat, lat+deltaLat
+
+	anyLon = minLat <= -90 || maxLat >= 90
+	if !anyLon {
+		deltaLon := deltaLat / math.Cos(lat*math.Pi/180)
+		minLon, maxLon = lon-deltaLon, lon+deltaLon
+		anyLon = minLon < -180 || maxLon > 180
+	}
+	return minLat, maxLat, minLon, maxLon, anyLon
+}
+
+// haversineSQL is the great-circle distance in meters between a place and
+// a point. Its arguments are SQL expressions, usually parameters, for the
+// point's latitude and longitude and earthRadiusM.
+func haversineSQL(lat, lon, radius string) string {
+	return fmt.Sprintf(`2 * %[3]s::float8 * ASIN(LEAST(1, SQRT(
+		POWER(SIN(RADIANS(latitude::float8 - %[1]s) / 2), 2) +
+		COS(RADIANS(%[1]s)) * COS(RADIANS(latitude::float8)) *
+		POWER(SIN(RADIANS(longitude::float8 - %[2]s) / 2), 2)
+	)))`, lat, lon, radius)
+}
+
+// FindNearby narrows the candidates to a bounding box around the point,
+// which the (latitude, longitude) index serves, and orders them by
+// haversine distance.
+func (r *PlaceRepository) FindNearby(lat, lon, radiusM float64, limit int) ([]*models.NearbyPlace, error) {
+	minLat, maxLat, minLon, maxLon, anyLon := boundingBox(lat, lon, radiusM)
+
+	rows, err := r.db.Query(`
+		SELECT `+placeColumns+`, distance_m FROM (
+			SELECT `+placeColumns+`, `+haversineSQL("$1", "$2", "$3")+` AS distance_m
+			FROM places
+			WHERE latitude BETWEEN $4 AND $5
+				AND ($6 OR longitude BETWEEN $7 AND $8)
+		) candidates
+		WHERE distance_m <= $9
+		ORDER BY distance_m, id
+		LIMIT $10
+	`, lat, lon, earthRadiusM, minLat, maxLat, anyLon, minLon, maxLon, radiusM, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanNearbyPlaces(rows)
+}
+
+func scanNearbyPlaces(rows *sql.Rows) ([]*models.NearbyPlace, error) {
+	defer rows.Close()
+
+	var places []*models.NearbyPlace
+	for rows.Next() {
+		place := &models.NearbyPlace{}
+		err := rows.Scan(
+			&place.ID, &place.Name, &place.Address, &place.Latitude, &place.Longitude,
+			&place.Category, &place.CreatedAt, &place.ReviewCount, &place.Rating, &place.DistanceM,
+		)
+		if err != nil {
+			return nil, err
+		}
+		places = append(places, place)
+	}
+
+	return places, rows.Err()
+}
+
+// placeGeography is the expression indexed by the optional PostGIS setup
+// in database/optional/postgis_places.sql; queries must use it verbatim
+// for the index to apply.
+const placeGeography = `(ST_SetSRID(ST_MakePoint(longitude::float8, latitude::float8), 4326)::geography)`
+
+// PostGISPlaceRepository finds nearby places with PostGIS, using a GiST
+// index on the places' coordinates.
+type PostGISPlaceRepository struct {
+	db *sql.DB
+}
+
+func NewPostGISPlaceRepository(db *sql.DB) *PostGISPlaceRepository {
+	return &PostGISPlaceRepository{db: db}
+}
+
+func (r *PostGISPlaceRepository) FindNearby(lat, lon, radiusM float64, limit int) ([]*models.NearbyPlace, error) {
+	rows, err := r.db.Query(`
+		SELECT `+placeColumns+`, ST_Distance(`+placeGeography+`, origin.point) AS distance_m
+		FROM places,
+			(SELECT ST_SetSRID(ST_MakePoint($2, $1), 4326)::geography AS point) origin
+		WHERE latitude IS NOT NULL AND longitude IS NOT NULL
+			AND ST_DWithin(`+placeGeography+`, origin.point, $3)
+		ORDER BY distance_m, id
+		LIMIT $4
+	`, lat, lon, radiusM, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanNearbyPlaces(rows)
+}
@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"sensory-navigator/models"
+)
+
+const placeColumns = `id, name, address, latitude, longitude, category, created_at, review_count, rating`
+
+type PlaceRepository struct {
+	db *sql.DB
+}
+
+func NewPlaceRepository(db *sql.DB) *PlaceRepository {
+	return &PlaceRepository{db: db}
+}
+
+func scanPlace(row rowScanner) (*models.Place, error) {
+	place := &models.Place{}
+	err := row.Scan(
+		&place.ID, &place.Name, &place.Address, &place.Latitude, &place.Longitude,
+		&place.Category, &place.CreatedAt, &place.ReviewCount, &place.Rating,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return place, nil
+}
+
+func (r *PlaceRepository) Create(req *models.CreatePlaceRequest) (*models.Place, error) {
+	return scanPlace(r.db.QueryRow(`
+		INSERT INTO places (name, address, latitude, longitude, category)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING `+placeColumns,
+		req.Name, req.Address, req.Latitude, req.Longitude, req.Category,
+	))
+}
+
+func (r *PlaceRepository) FindByID(id int64) (*models.Place, error) {
+	return scanPlace(r.db.QueryRow(`SELECT `+placeColumns+` FROM places WHERE id = $1`, id))
+}
+
+// FindAll lists places by name. An empty category lists every category.
+func (r *PlaceRepository) FindAll(category string, limit, offset int) ([]*models.Place, error) {
+	rows, err := r.db.Query(`
+		SELECT `+placeColumns+` FROM places
+		WHERE $1 = '' OR category = $1
+		ORDER BY name, id
+		LIMIT $2 OFFSET $3
+	`, category, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var places []*models.Place
+	for rows.Next() {
+		place, err := scanPlace(rows)
+		if err != nil {
+			return nil, err
+		}
+		places = append(places, place)
+	}
+
+	return places, rows.Err()
+}
+
+func (r *PlaceRepository) Count(category string) (int, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM places WHERE $1 = '' OR category = $1
+	`, category).Scan(&count)
+	return count, err
+}
+
+// Update changes the fields set in req and returns sql.ErrNoRows for an
+// unknown place.
+func (r *PlaceRepository) Update(id int64, req *models.UpdatePlaceRequest) (*models.Place, error) {
+	// Build dynamic update query
+	var sets []string
+	args := []interface{}{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s = $%d", column, len(args)))
+	}
+
+	if req.Name != nil {
+		set("name", *req.Name)
+	}
+	if req.Address != nil {
+		set("address", *req.Address)
+	}
+	if req.Latitude != nil {
+		set("latitude", *req.Latitude)
+	}
+	if req.Longitude != nil {
+		set("longitude", *req.Longitude)
+	}
+	if req.Category != nil {
+		set("category", *req.Category)
+	}
+	if len(sets) == 0 {
+		return r.FindByID(id)
+	}
+
+	args = append(args, id)
+	query := fmt.Sprintf("UPDATE places SET %s WHERE id = $%d RETURNING %s",
+		strings.Join(sets, ", "), len(args), placeColumns)
+	return scanPlace(r.db.QueryRow(query, args...))
+}
+
+// Delete removes a place together with its reviews and favorites. It
+// returns sql.ErrNoRows for an unknown place.
+func (r *PlaceRepository) Delete(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM places WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// IsForeignKeyViolation reports whether err was caused by a reference to a
+// row that does not exist, e.g. a review of an unknown place.
+func IsForeignKeyViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23503"
+}
@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"sensory-navigator/models"
+)
+
+// fitSQL is the fit of the place with ID candidates.id for the user whose
+// ID is the parameter userID, from 0 to 1, or NULL if no ratings match the
+// user's profile. It must agree with services.FitPlace: the weighted mean,
+// over the dimensions the user set, of the share of ratings within their
+// tolerance.
+func fitSQL(userID string) string {
+	return `(
+		SELECT SUM(up.weight * within.share) / SUM(up.weight)
+		FROM user_sensory_preferences up
+		JOIN place_sensory_stats s
+			ON s.place_id = candidates.id AND s.dimension = up.dimension AND s.rating_count > 0
+		CROSS JOIN LATERAL (
+			SELECT COALESCE(SUM(h), 0)::float8 / s.rating_count AS share
+			FROM unnest(CASE
+				WHEN up.dimension = '` + models.DimensionAccessibility + `' THEN s.histogram[up.tolerance:5]
+				ELSE s.histogram[1:up.tolerance]
+			END) AS h
+		) within
+		WHERE up.user_id = ` + userID + `
+	)`
+}
+
+// Search finds places matching the filter, a page at a time: results
+// continue after filter.After in the filter's sort order, with ID as the
+// tie-breaker. Rating and review count sorts walk an index; fit and
+// distance are computed for every matching place.
+func (r *PlaceRepository) Search(filter *models.PlaceSearchFilter) ([]*models.PlaceSearchResult, error) {
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions := []string{"TRUE"}
+	distance := "NULL::float8"
+	radius := "TRUE"
+
+	if filter.Category != "" {
+		conditions = append(conditions, "category = "+arg(filter.Category))
+	}
+	if filter.MinReviews > 0 {
+		conditions = append(conditions, "review_count >= "+arg(filter.MinReviews))
+	}
+	if near := filter.Near; near != nil {
+		minLat, maxLat, minLon, maxLon, anyLon := boundingBox(near.Lat, near.Lon, near.RadiusM)
+		conditions = append(conditions, fmt.Sprintf("latitude BETWEEN %s AND %s", arg(minLat), arg(maxLat)))
+		if !anyLon {
+			conditions = append(conditions, fmt.Sprintf("longitude BETWEEN %s AND %s", arg(minLon), arg(maxLon)))
+		}
+		distance = haversineSQL(arg(near.Lat), arg(near.Lon), arg(earthRadiusM))
+		radius = "distance_m <= " + arg(near.RadiusM)
+	}
+
+	for _, dimension := range models.SensoryDimensions {
+		bounds, ok := filter.Ratings[dimension]
+		if !ok {
+			continue
+		}
+		condition := "s.place_id = places.id AND s.dimension = " + arg(dimension)
+		if bounds.Min != nil {
+			condition += " AND s.rating_mean >= " + arg(*bounds.Min)
+		}
+		if bounds.Max != nil {
+			condition += " AND s.rating_mean <= " + arg(*bounds.Max)
+		}
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM place_sensory_stats s WHERE "+condition+")")
+	}
+
+	// Sort keys must be written like their indexes for those to apply
+	var sortKey string
+	descending := true
+	switch filter.Sort {
+	case models.PlaceSortFit:
+		sortKey = "COALESCE(" + fitSQL(arg(filter.UserID)) + ", -1)"
+	case models.PlaceSortDistance:
+		if filter.Near == nil {
+			return nil, errors.New("distance sort needs a search area")
+		}
+		sortKey = "distance_m"
+		descending = false
+	case models.PlaceSortRating:
+		sortKey = "COALESCE(rating, 0)"
+	case models.PlaceSortReviewCount:
+		sortKey = "review_count"
+	default:
+		return nil, fmt.Errorf("unknown place sort %q", filter.Sort)
+	}
+
+	direction, comparison := "DESC", "<"
+	if !descending {
+		direction, comparison = "ASC", ">"
+	}
+
+	after := "TRUE"
+	if c := filter.After; c != nil {
+		var value interface{} = c.Value
+		if filter.Sort == models.PlaceSortReviewCount {
+			value = int64(c.Value)
+		}
+		after = fmt.Sprintf("(sort_value, id) %s (%s, %s)", comparison, arg(value), arg(c.ID))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %[1]s, distance_m, sort_value FROM (
+			SELECT %[1]s, distance_m, %[2]s AS sort_value FROM (
+				SELECT %[1]s, %[3]s AS distance_m
+				FROM places
+				WHERE %[4]s
+			) candidates
+			WHERE %[5]s
+		) ranked
+		WHERE %[6]s
+		ORDER BY sort_value %[7]s, id %[7]s
+		LIMIT %[8]s
+	`, placeColumns, sortKey, distance, strings.Join(conditions, " AND "), radius, after, direction, arg(filter.Limit))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []*models.PlaceSearchResult
+	for rows.Next() {
+		result := &models.PlaceSearchResult{}
+		err := rows.Scan(
+			&result.ID, &result.Name, &result.Address, &result.Latitude, &result.Longitude,
+			&result.Category, &result.CreatedAt, &result.ReviewCount, &result.Rating,
+			&result.DistanceM, &result.SortValue,
+		)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"html"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"sensory-navigator/models"
+)
+
+// textQuery turns the search text in parameter $1 into a query matching
+// either its Russian or its English reading, as the search vectors hold
+// both.
+const textQuery = `(websearch_to_tsquery('russian', $1) || websearch_to_tsquery('english', $1))`
+
+// Highlighted words are marked with control characters, which review text
+// cannot usefully contain, so the snippet can be escaped before the marks
+// become HTML.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+const headlineOptions = "StartSel=" + highlightStart + ", StopSel=" + highlightStop +
+	", MaxWords=30, MinWords=10, MaxFragments=2"
+
+// SearchText ranks places by how well their name and address, and their
+// best matching review, match the query.
+func (r *PlaceRepository) SearchText(query string, limit, offset int) ([]*models.PlaceTextMatch, error) {
+	rows, err := r.db.Query(`
+		WITH q AS (SELECT `+textQuery+` AS query),
+		matches AS (
+			SELECT id AS place_id, ts_rank(search_vector, q.query) AS rank
+			FROM places, q
+			WHERE search_vector @@ q.query
+			UNION ALL
+			-- Reviews count half as much as the place's own text
+			SELECT place_id, MAX(ts_rank(search_vector, q.query)) / 2
+			FROM reviews, q
+			WHERE search_vector @@ q.query
+			GROUP BY place_id
+		),
+		ranked AS (
+			SELECT place_id, SUM(rank) AS rank
+			FROM matches
+			GROUP BY place_id
+		)
+		SELECT `+placeColumns+`, rank
+		FROM places
+		JOIN ranked ON ranked.place_id = places.id
+		ORDER BY rank DESC, id
+		LIMIT $2 OFFSET $3
+	`, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []*models.PlaceTextMatch
+	for rows.Next() {
+		match := &models.PlaceTextMatch{}
+		err := rows.Scan(
+			&match.ID, &match.Name, &match.Address, &match.Latitude, &match.Longitude,
+			&match.Category, &match.CreatedAt, &match.ReviewCount, &match.Rating, &match.Rank,
+		)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, match)
+	}
+
+	return matches, rows.Err()
+}
+
+// FindReviewSnippets returns, for each of the places, up to perPlace
+// fragments of its reviews best matching the query.
+func (r *PlaceRepository) FindReviewSnippets(query string, placeIDs []int64, perPlace int) ([]*models.ReviewSnippet, error) {
+	if len(placeIDs) == 0 {
+		return nil, nil
+	}
+
+	rows, err := r.db.Query(`
+		WITH q AS (SELECT `+textQuery+` AS query)
+		SELECT id, place_id,
+			ts_headline('russian', text, q.query, $4)
+		FROM (
+			SELECT r.id, r.place_id, r.text,
+				ROW_NUMBER() OVER (PARTITION BY r.place_id ORDER BY ts_rank(r.search_vector, q.query) DESC, r.id) AS n
+			FROM reviews r, q
+			WHERE r.place_id = ANY($2) AND r.search_vector @@ q.query
+		) best, q
+		WHERE n <= $3
+		ORDER BY place_id, n
+	`, query, pq.Array(placeIDs), perPlace, headlineOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snippets []*models.ReviewSnippet
+	for rows.Next() {
+		snippet := &models.ReviewSnippet{}
+		if err := rows.Scan(&snippet.ReviewID, &snippet.PlaceID, &snippet.Snippet); err != nil {
+			return nil, err
+		}
+		snippet.Snippet = highlight(snippet.Snippet)
+		snippets = append(snippets, snippet)
+	}
+
+	return snippets, rows.Err()
+}
+
+// Autocomplete suggests places whose name contains a word similar to the
+// prefix or starts with it, most similar first.
+func (r *PlaceRepository) Autocomplete(prefix string, limit int) ([]*models.Place, error) {
+	pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+
+	rows, err := r.db.Query(`
+		SELECT `+placeColumns+` FROM places
+		WHERE $1 <% name OR name ILIKE $2
+		ORDER BY word_similarity($1, name) DESC, name, id
+		LIMIT $3
+	`, prefix, pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var places []*models.Place
+	for rows.Next() {
+		place, err := scanPlace(rows)
+		if err != nil {
+			return nil, err
+		}
+		places = append(places, place)
+	}
+
+	return places, rows.Err()
+}
+
+// highlight escapes a headline and turns its marks into <mark> tags.
+func highlight(headline string) string {
+	escaped := html.EscapeString(headline)
+	return strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>").Replace(escaped)
+}
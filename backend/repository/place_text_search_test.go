@@ -0,0 +1,24 @@
+package repository
+
+import "testing"
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name     string
+		headline string
+		want     string
+	}{
+		{name: "no match", headline: "quiet hall", want: "quiet hall"},
+		{name: "marked words", headline: "a \x02quiet\x03 \x02hall\x03", want: "a <mark>quiet</mark> <mark>hall</mark>"},
+		{name: "markup in the review", headline: "<b>\x02quiet\x03</b> & calm", want: "&lt;b&gt;<mark>quiet</mark>&lt;/b&gt; &amp; calm"},
+		{name: "literal mark tag", headline: "<mark>loud</mark>", want: "&lt;mark&gt;loud&lt;/mark&gt;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlight(tt.headline); got != tt.want {
+				t.Errorf("highlight(%q) = %q, want %q", tt.headline, got, tt.want)
+			}
+		})
+	}
+}
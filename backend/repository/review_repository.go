@@ -14,20 +14,25 @@ func NewReviewRepository(db *sql.DB) *ReviewRepository {
 	return &ReviewRepository{db: db}
 }
 
-func (r *ReviewRepository) Create(userID, placeID int64, req *models.CreateReviewRequest) (*models.Review, error) {
+// Create stores a review; visitHourOfWeek is the bucket of req's visit
+// time, see models.VisitTime.
+func (r *ReviewRepository) Create(userID, placeID int64, req *models.CreateReviewRequest, visitHourOfWeek *int) (*models.Review, error) {
 	review := &models.Review{}
 	err := r.db.QueryRow(`
 		INSERT INTO reviews (user_id, place_id, text, sensory_rating, lighting_rating, 
-			sound_level_rating, crowding_rating, accessibility_rating, overall_rating)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			sound_level_rating, crowding_rating, accessibility_rating, overall_rating,
+			visited_at, visit_hour_of_week)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING id, user_id, place_id, text, sensory_rating, lighting_rating, 
-			sound_level_rating, crowding_rating, accessibility_rating, overall_rating, created_at, updated_at
+			sound_level_rating, crowding_rating, accessibility_rating, overall_rating,
+			visited_at, visit_hour_of_week, created_at, updated_at
 	`, userID, placeID, req.Text, req.SensoryRating, req.LightingRating,
-		req.SoundLevelRating, req.CrowdingRating, req.AccessibilityRating, req.OverallRating).Scan(
+		req.SoundLevelRating, req.CrowdingRating, req.AccessibilityRating, req.OverallRating,
+		req.VisitedAt, visitHourOfWeek).Scan(
 		&review.ID, &review.UserID, &review.PlaceID, &review.Text,
 		&review.SensoryRating, &review.LightingRating, &review.SoundLevelRating,
 		&review.CrowdingRating, &review.AccessibilityRating, &review.OverallRating,
-		&review.CreatedAt, &review.UpdatedAt,
+		&review.VisitedAt, &review.VisitHourOfWeek, &review.CreatedAt, &review.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -38,14 +43,15 @@ func (r *ReviewRepository) Create(userID, placeID int64, req *models.CreateRevie
 func (r *ReviewRepository) FindByID(id int64) (*models.Review, error) {
 	review := &models.Review{}
 	err := r.db.QueryRow(`
-		SELECT id, user_id, place_id, text, sensory_rating, lighting_rating,
-			sound_level_rating, crowding_rating, accessibility_rating, overall_rating, created_at, updated_at
+		SELECT id, COALESCE(user_id, 0), place_id, text, sensory_rating, lighting_rating,
+			sound_level_rating, crowding_rating, accessibility_rating, overall_rating,
+			visited_at, visit_hour_of_week, created_at, updated_at
 		FROM reviews WHERE id = $1
 	`, id).Scan(
 		&review.ID, &review.UserID, &review.PlaceID, &review.Text,
 		&review.SensoryRating, &review.LightingRating, &review.SoundLevelRating,
 		&review.CrowdingRating, &review.AccessibilityRating, &review.OverallRating,
-		&review.CreatedAt, &review.UpdatedAt,
+		&review.VisitedAt, &review.VisitHourOfWeek, &review.CreatedAt, &review.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -55,11 +61,11 @@ func (r *ReviewRepository) FindByID(id int64) (*models.Review, error) {
 
 func (r *ReviewRepository) FindByPlaceID(placeID int64, limit, offset int) ([]*models.ReviewResponse, error) {
 	rows, err := r.db.Query(`
-		SELECT r.id, r.user_id, r.place_id, r.text, r.sensory_rating, r.lighting_rating,
+		SELECT r.id, COALESCE(r.user_id, 0), r.place_id, r.text, r.sensory_rating, r.lighting_rating,
 			r.sound_level_rating, r.crowding_rating, r.accessibility_rating, r.overall_rating, 
-			r.created_at, r.updated_at, u.username
+			r.visited_at, r.visit_hour_of_week, r.created_at, r.updated_at, u.username
 		FROM reviews r
-		JOIN users u ON r.user_id = u.id
+		LEFT JOIN users u ON r.user_id = u.id
 		WHERE r.place_id = $1
 		ORDER BY r.created_at DESC
 		LIMIT $2 OFFSET $3
@@ -72,18 +78,21 @@ func (r *ReviewRepository) FindByPlaceID(placeID int64, limit, offset int) ([]*m
 	var reviews []*models.ReviewResponse
 	for rows.Next() {
 		review := &models.Review{}
-		var username string
+		var username sql.NullString
 		err := rows.Scan(
 			&review.ID, &review.UserID, &review.PlaceID, &review.Text,
 			&review.SensoryRating, &review.LightingRating, &review.SoundLevelRating,
 			&review.CrowdingRating, &review.AccessibilityRating, &review.OverallRating,
-			&review.CreatedAt, &review.UpdatedAt, &username,
+			&review.VisitedAt, &review.VisitHourOfWeek, &review.CreatedAt, &review.UpdatedAt, &username,
 		)
 		if err != nil {
 			return nil, err
 		}
 		resp := review.ToResponse()
-		resp.Username = username
+		resp.Username = username.String
+		if !username.Valid {
+			resp.Username = models.FormerUserName
+		}
 		reviews = append(reviews, &resp)
 	}
 	return reviews, nil
@@ -93,7 +102,7 @@ func (r *ReviewRepository) FindByUserID(userID int64, limit, offset int) ([]*mod
 	rows, err := r.db.Query(`
 		SELECT r.id, r.user_id, r.place_id, r.text, r.sensory_rating, r.lighting_rating,
 			r.sound_level_rating, r.crowding_rating, r.accessibility_rating, r.overall_rating, 
-			r.created_at, r.updated_at, p.name
+			r.visited_at, r.visit_hour_of_week, r.created_at, r.updated_at, p.name
 		FROM reviews r
 		JOIN places p ON r.place_id = p.id
 		WHERE r.user_id = $1
@@ -113,7 +122,7 @@ func (r *ReviewRepository) FindByUserID(userID int64, limit, offset int) ([]*mod
 			&review.ID, &review.UserID, &review.PlaceID, &review.Text,
 			&review.SensoryRating, &review.LightingRating, &review.SoundLevelRating,
 			&review.CrowdingRating, &review.AccessibilityRating, &review.OverallRating,
-			&review.CreatedAt, &review.UpdatedAt, &placeName,
+			&review.VisitedAt, &review.VisitHourOfWeek, &review.CreatedAt, &review.UpdatedAt, &placeName,
 		)
 		if err != nil {
 			return nil, err
@@ -125,7 +134,9 @@ func (r *ReviewRepository) FindByUserID(userID int64, limit, offset int) ([]*mod
 	return reviews, nil
 }
 
-func (r *ReviewRepository) Update(id int64, req *models.UpdateReviewRequest) (*models.Review, error) {
+// Update changes the fields set in req. A visit time replaces the old one:
+// giving only the day and hour clears visited_at.
+func (r *ReviewRepository) Update(id int64, req *models.UpdateReviewRequest, visitHourOfWeek *int) (*models.Review, error) {
 	review := &models.Review{}
 	err := r.db.QueryRow(`
 		UPDATE reviews SET 
@@ -136,16 +147,20 @@ func (r *ReviewRepository) Update(id int64, req *models.UpdateReviewRequest) (*m
 			crowding_rating = COALESCE($5, crowding_rating),
 			accessibility_rating = COALESCE($6, accessibility_rating),
 			overall_rating = COALESCE($7, overall_rating),
+			visited_at = CASE WHEN $8::smallint IS NULL THEN visited_at ELSE $9 END,
+			visit_hour_of_week = COALESCE($8, visit_hour_of_week),
 			updated_at = CURRENT_TIMESTAMP
-		WHERE id = $8
-		RETURNING id, user_id, place_id, text, sensory_rating, lighting_rating,
-			sound_level_rating, crowding_rating, accessibility_rating, overall_rating, created_at, updated_at
+		WHERE id = $10
+		RETURNING id, COALESCE(user_id, 0), place_id, text, sensory_rating, lighting_rating,
+			sound_level_rating, crowding_rating, accessibility_rating, overall_rating,
+			visited_at, visit_hour_of_week, created_at, updated_at
 	`, req.Text, req.SensoryRating, req.LightingRating, req.SoundLevelRating,
-		req.CrowdingRating, req.AccessibilityRating, req.OverallRating, id).Scan(
+		req.CrowdingRating, req.AccessibilityRating, req.OverallRating,
+		visitHourOfWeek, req.VisitedAt, id).Scan(
 		&review.ID, &review.UserID, &review.PlaceID, &review.Text,
 		&review.SensoryRating, &review.LightingRating, &review.SoundLevelRating,
 		&review.CrowdingRating, &review.AccessibilityRating, &review.OverallRating,
-		&review.CreatedAt, &review.UpdatedAt,
+		&review.VisitedAt, &review.VisitHourOfWeek, &review.CreatedAt, &review.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"database/sql"
+
+	"sensory-navigator/models"
+)
+
+type SensoryPreferenceRepository struct {
+	db *sql.DB
+}
+
+func NewSensoryPreferenceRepository(db *sql.DB) *SensoryPreferenceRepository {
+	return &SensoryPreferenceRepository{db: db}
+}
+
+func (r *SensoryPreferenceRepository) FindByUserID(userID int64) ([]*models.SensoryPreference, error) {
+	rows, err := r.db.Query(`
+		SELECT user_id, dimension, tolerance, weight, updated_at
+		FROM user_sensory_preferences
+		WHERE user_id = $1
+		ORDER BY dimension
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prefs []*models.SensoryPreference
+	for rows.Next() {
+		p := &models.SensoryPreference{}
+		if err := rows.Scan(&p.UserID, &p.Dimension, &p.Tolerance, &p.Weight, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		prefs = append(prefs, p)
+	}
+
+	return prefs, rows.Err()
+}
+
+// Replace swaps the user's preferences for the given ones.
+func (r *SensoryPreferenceRepository) Replace(userID int64, settings map[string]models.SensoryPreferenceSetting) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_sensory_preferences WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	for dimension, setting := range settings {
+		_, err := tx.Exec(`
+			INSERT INTO user_sensory_preferences (user_id, dimension, tolerance, weight)
+			VALUES ($1, $2, $3, $4)
+		`, userID, dimension, setting.Tolerance, setting.Weight)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/lib/pq"
+
+	"sensory-navigator/models"
+)
+
+// SensoryStatsRepository reads place_sensory_stats, which a trigger on
+// reviews keeps up to date.
+type SensoryStatsRepository struct {
+	db *sql.DB
+}
+
+func NewSensoryStatsRepository(db *sql.DB) *SensoryStatsRepository {
+	return &SensoryStatsRepository{db: db}
+}
+
+// FindByPlaceID returns the statistics of the dimensions the place has
+// ratings for.
+func (r *SensoryStatsRepository) FindByPlaceID(placeID int64) ([]*models.SensoryStats, error) {
+	rows, err := r.db.Query(`
+		SELECT place_id, dimension, rating_count, rating_sum, rating_sum_sq, histogram, updated_at
+		FROM place_sensory_stats
+		WHERE place_id = $1 AND rating_count > 0
+	`, placeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*models.SensoryStats
+	for rows.Next() {
+		s, err := scanSensoryStats(rows)
+		if err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+
+	return stats, rows.Err()
+}
+
+// FindByPlaceIDs is FindByPlaceID for several places at once, keyed by
+// place ID.
+func (r *SensoryStatsRepository) FindByPlaceIDs(placeIDs []int64) (map[int64][]*models.SensoryStats, error) {
+	stats := make(map[int64][]*models.SensoryStats)
+	if len(placeIDs) == 0 {
+		return stats, nil
+	}
+
+	rows, err := r.db.Query(`
+		SELECT place_id, dimension, rating_count, rating_sum, rating_sum_sq, histogram, updated_at
+		FROM place_sensory_stats
+		WHERE place_id = ANY($1) AND rating_count > 0
+	`, pq.Array(placeIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		s, err := scanSensoryStats(rows)
+		if err != nil {
+			return nil, err
+		}
+		stats[s.PlaceID] = append(stats[s.PlaceID], s)
+	}
+
+	return stats, rows.Err()
+}
+
+func scanSensoryStats(row rowScanner) (*models.SensoryStats, error) {
+	s := &models.SensoryStats{}
+	err := row.Scan(
+		&s.PlaceID, &s.Dimension, &s.Count, &s.Sum, &s.SumSquares,
+		pq.Array(&s.Histogram), &s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Recompute rebuilds the statistics and review counts of one place, or of
+// every place if placeID is 0, from the reviews. Review writes wait until
+// it is done so none of them is counted twice or missed.
+func (r *SensoryStatsRepository) Recompute(placeID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`LOCK TABLE reviews IN SHARE MODE`); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`SELECT recompute_place_sensory_stats(NULLIF($1, 0)::integer)`, placeID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FindByHourOfWeek aggregates the ratings of a place's reviews by the hour
+// of the week of the visit. Reviews without a visit time are left out, as
+// are hours without reviews.
+func (r *SensoryStatsRepository) FindByHourOfWeek(placeID int64) ([]*models.SensoryTimeBucket, error) {
+	buckets := make(map[int]*models.SensoryTimeBucket)
+	var order []int
+
+	rows, err := r.db.Query(`
+		SELECT visit_hour_of_week, COUNT(*)
+		FROM reviews
+		WHERE place_id = $1 AND visit_hour_of_week IS NOT NULL
+		GROUP BY visit_hour_of_week
+		ORDER BY visit_hour_of_week
+	`, placeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bucket := &models.SensoryTimeBucket{}
+		if err := rows.Scan(&bucket.HourOfWeek, &bucket.ReviewCount); err != nil {
+			return nil, err
+		}
+		buckets[bucket.HourOfWeek] = bucket
+		order = append(order, bucket.HourOfWeek)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Same aggregates as recompute_place_sensory_stats, per hour
+	rows, err = r.db.Query(`
+		SELECT r.visit_hour_of_week, d.dimension, COUNT(*), SUM(d.rating), SUM(d.rating * d.rating),
+			ARRAY[
+				COUNT(*) FILTER (WHERE d.rating = 1),
+				COUNT(*) FILTER (WHERE d.rating = 2),
+				COUNT(*) FILTER (WHERE d.rating = 3),
+				COUNT(*) FILTER (WHERE d.rating = 4),
+				COUNT(*) FILTER (WHERE d.rating = 5)
+			]
+		FROM reviews r
+		CROSS JOIN LATERAL (VALUES
+			('sensory', r.sensory_rating),
+			('lighting', r.lighting_rating),
+			('sound_level', r.sound_level_rating),
+			('crowding', r.crowding_rating),
+			('accessibility', r.accessibility_rating)
+		) AS d(dimension, rating)
+		WHERE r.place_id = $1 AND r.visit_hour_of_week IS NOT NULL AND d.rating IS NOT NULL
+		GROUP BY r.visit_hour_of_week, d.dimension
+	`, placeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hourOfWeek int
+		s := &models.SensoryStats{PlaceID: placeID}
+		err := rows.Scan(&hourOfWeek, &s.Dimension, &s.Count, &s.Sum, &s.SumSquares, pq.Array(&s.Histogram))
+		if err != nil {
+			return nil, err
+		}
+		if bucket, ok := buckets[hourOfWeek]; ok {
+			bucket.Stats = append(bucket.Stats, s)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]*models.SensoryTimeBucket, 0, len(order))
+	for _, hourOfWeek := range order {
+		result = append(result, buckets[hourOfWeek])
+	}
+	return result, nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+// TokenRevocationRepository stores the per-user access token generation
+// and the denylist of revoked token IDs.
+type TokenRevocationRepository struct {
+	db *sql.DB
+}
+
+func NewTokenRevocationRepository(db *sql.DB) *TokenRevocationRepository {
+	return &TokenRevocationRepository{db: db}
+}
+
+func (r *TokenRevocationRepository) Generation(userID int64) (int64, error) {
+	var generation int64
+	err := r.db.QueryRow(`SELECT token_generation FROM users WHERE id = $1`, userID).Scan(&generation)
+	return generation, err
+}
+
+func (r *TokenRevocationRepository) BumpGeneration(userID int64) (int64, error) {
+	var generation int64
+	err := r.db.QueryRow(`
+		UPDATE users SET token_generation = token_generation + 1
+		WHERE id = $1
+		RETURNING token_generation
+	`, userID).Scan(&generation)
+	return generation, err
+}
+
+// Deny adds a token ID to the denylist until the token would have expired
+// anyway. Expired entries are purged on the way.
+func (r *TokenRevocationRepository) Deny(jti string, expiresAt time.Time) error {
+	if _, err := r.db.Exec(`DELETE FROM revoked_access_tokens WHERE expires_at < CURRENT_TIMESTAMP`); err != nil {
+		return err
+	}
+
+	_, err := r.db.Exec(`
+		INSERT INTO revoked_access_tokens (jti, expires_at)
+		VALUES ($1, $2)
+		ON CONFLICT (jti) DO NOTHING
+	`, jti, expiresAt)
+	return err
+}
+
+func (r *TokenRevocationRepository) IsDenied(jti string) (bool, error) {
+	var denied bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM revoked_access_tokens WHERE jti = $1)
+	`, jti).Scan(&denied)
+	return denied, err
+}
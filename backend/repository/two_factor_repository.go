@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"database/sql"
+
+	"sensory-navigator/models"
+)
+
+type TwoFactorRepository struct {
+	db *sql.DB
+}
+
+func NewTwoFactorRepository(db *sql.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+func (r *TwoFactorRepository) FindTOTP(userID int64) (*models.TOTPSecret, error) {
+	totp := &models.TOTPSecret{}
+	err := r.db.QueryRow(`
+		SELECT user_id, secret, confirmed_at, last_used_step, created_at
+		FROM user_totp WHERE user_id = $1
+	`, userID).Scan(&totp.UserID, &totp.Secret, &totp.ConfirmedAt, &totp.LastUsedStep, &totp.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return totp, nil
+}
+
+// IsEnabled reports whether the user has confirmed two-factor enrollment.
+func (r *TwoFactorRepository) IsEnabled(userID int64) (bool, error) {
+	var enabled bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM user_totp WHERE user_id = $1 AND confirmed_at IS NOT NULL)
+	`, userID).Scan(&enabled)
+	return enabled, err
+}
+
+// SavePendingTOTP stores a new unconfirmed secret, replacing any earlier
+// unconfirmed one. A confirmed secret is never overwritten.
+func (r *TwoFactorRepository) SavePendingTOTP(userID int64, secret string) error {
+	_, err := r.db.Exec(`
+		INSERT INTO user_totp (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = EXCLUDED.secret,
+			last_used_step = 0,
+			created_at = CURRENT_TIMESTAMP
+		WHERE user_totp.confirmed_at IS NULL
+	`, userID, secret)
+	return err
+}
+
+func (r *TwoFactorRepository) ConfirmTOTP(userID int64) error {
+	_, err := r.db.Exec(`
+		UPDATE user_totp SET confirmed_at = CURRENT_TIMESTAMP WHERE user_id = $1
+	`, userID)
+	return err
+}
+
+// UseTOTPStep records step as the last accepted time step. It returns false
+// if that step or a later one was already used.
+func (r *TwoFactorRepository) UseTOTPStep(userID, step int64) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE user_totp SET last_used_step = $2
+		WHERE user_id = $1 AND last_used_step < $2
+	`, userID, step)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// Delete disables two-factor authentication and drops the recovery codes.
+func (r *TwoFactorRepository) Delete(userID int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM user_totp WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes invalidates all previous recovery codes of the user
+// and stores digests of the new ones.
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(userID int64, codes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM user_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, code := range codes {
+		_, err := tx.Exec(`
+			INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)
+		`, userID, hashToken(code))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode marks a recovery code as used. It returns false if the
+// code is unknown or was used before.
+func (r *TwoFactorRepository) UseRecoveryCode(userID int64, code string) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE user_recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`, userID, hashToken(code))
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
@@ -1,10 +1,14 @@
 package repository
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
 	"time"
 
+	"github.com/lib/pq"
+
 	"sensory-navigator/models"
 )
 
@@ -16,15 +20,21 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-func (r *UserRepository) Create(email, passwordHash, username string) (*models.User, error) {
+// userColumns lists the users columns in the order expected by scanUser.
+// Accounts created through an external identity provider have no password.
+const userColumns = `id, email, COALESCE(password_hash, ''), username, avatar_url, birth_date,
+	email_verified_at, role, deletion_scheduled_at, token_generation, created_at, updated_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
 	user := &models.User{}
-	err := r.db.QueryRow(`
-		INSERT INTO users (email, password_hash, username)
-		VALUES ($1, $2, $3)
-		RETURNING id, email, password_hash, username, avatar_url, birth_date, created_at, updated_at
-	`, email, passwordHash, username).Scan(
+	err := row.Scan(
 		&user.ID, &user.Email, &user.PasswordHash, &user.Username,
-		&user.AvatarURL, &user.BirthDate, &user.CreatedAt, &user.UpdatedAt,
+		&user.AvatarURL, &user.BirthDate, &user.EmailVerifiedAt, &user.Role,
+		&user.DeletionScheduledAt, &user.TokenGeneration, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
@@ -32,34 +42,28 @@ func (r *UserRepository) Create(email, passwordHash, username string) (*models.U
 	return user, nil
 }
 
+func (r *UserRepository) Create(email, passwordHash, username string) (*models.User, error) {
+	return scanUser(r.db.QueryRow(`
+		INSERT INTO users (email, password_hash, username)
+		VALUES ($1, $2, $3)
+		RETURNING `+userColumns, email, passwordHash, username))
+}
+
+// CreateExternal creates a user signed up through an identity provider. The
+// account has no password; emailVerified is taken from the provider.
+func (r *UserRepository) CreateExternal(email, username string, emailVerified bool) (*models.User, error) {
+	return scanUser(r.db.QueryRow(`
+		INSERT INTO users (email, username, email_verified_at)
+		VALUES ($1, $2, CASE WHEN $3 THEN CURRENT_TIMESTAMP END)
+		RETURNING `+userColumns, email, username, emailVerified))
+}
+
 func (r *UserRepository) FindByEmail(email string) (*models.User, error) {
-	user := &models.User{}
-	err := r.db.QueryRow(`
-		SELECT id, email, password_hash, username, avatar_url, birth_date, created_at, updated_at
-		FROM users WHERE email = $1
-	`, email).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.Username,
-		&user.AvatarURL, &user.BirthDate, &user.CreatedAt, &user.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return user, nil
+	return scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE email = $1`, email))
 }
 
 func (r *UserRepository) FindByID(id int64) (*models.User, error) {
-	user := &models.User{}
-	err := r.db.QueryRow(`
-		SELECT id, email, password_hash, username, avatar_url, birth_date, created_at, updated_at
-		FROM users WHERE id = $1
-	`, id).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.Username,
-		&user.AvatarURL, &user.BirthDate, &user.CreatedAt, &user.UpdatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-	return user, nil
+	return scanUser(r.db.QueryRow(`SELECT `+userColumns+` FROM users WHERE id = $1`, id))
 }
 
 func (r *UserRepository) Update(id int64, username *string, avatarURL *string, birthDate *string) (*models.User, error) {
@@ -86,17 +90,156 @@ func (r *UserRepository) Update(id int64, username *string, avatarURL *string, b
 
 	query += fmt.Sprintf(" WHERE id = $%d", argNum)
 	args = append(args, id)
-	query += " RETURNING id, email, password_hash, username, avatar_url, birth_date, created_at, updated_at"
+	query += " RETURNING " + userColumns
 
-	user := &models.User{}
-	err := r.db.QueryRow(query, args...).Scan(
-		&user.ID, &user.Email, &user.PasswordHash, &user.Username,
-		&user.AvatarURL, &user.BirthDate, &user.CreatedAt, &user.UpdatedAt,
+	return scanUser(r.db.QueryRow(query, args...))
+}
+
+// hashToken returns the digest under which refresh and password reset tokens
+// are stored, so that a database leak does not expose usable tokens.
+// Tokens are 256-bit random values, so a plain SHA-256 is sufficient.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// MarkEmailVerified records the moment the user confirmed their address.
+// Already verified users keep their original timestamp.
+func (r *UserRepository) MarkEmailVerified(id int64) error {
+	_, err := r.db.Exec(`
+		UPDATE users SET email_verified_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND email_verified_at IS NULL
+	`, id)
+	return err
+}
+
+// FindAll lists users, newest first. An empty role lists every role.
+func (r *UserRepository) FindAll(role string, limit, offset int) ([]*models.User, error) {
+	rows, err := r.db.Query(`
+		SELECT `+userColumns+` FROM users
+		WHERE $1 = '' OR role = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3
+	`, role, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (r *UserRepository) UpdateRole(id int64, role string) (*models.User, error) {
+	return scanUser(r.db.QueryRow(`
+		UPDATE users SET role = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+		RETURNING `+userColumns, role, id))
+}
+
+// UpdateRoleByEmail sets the role of the account with the given email and
+// reports whether such an account exists.
+func (r *UserRepository) UpdateRoleByEmail(email, role string) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE users SET role = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE email = $2
+	`, role, email)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// FindLanguage returns the user's preferred language, or an empty string
+// if the user has no settings row yet.
+func (r *UserRepository) FindLanguage(userID int64) (string, error) {
+	var language sql.NullString
+	err := r.db.QueryRow(`SELECT language FROM user_settings WHERE user_id = $1`, userID).Scan(&language)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return language.String, err
+}
+
+// FindSettings returns the user's preferences, or nil if the user has no
+// settings row yet.
+func (r *UserRepository) FindSettings(userID int64) (*models.UserSettings, error) {
+	settings := &models.UserSettings{}
+	err := r.db.QueryRow(`
+		SELECT COALESCE(notifications_enabled, TRUE), COALESCE(email_notifications, TRUE),
+			COALESCE(language, ''), COALESCE(theme, '')
+		FROM user_settings WHERE user_id = $1
+	`, userID).Scan(
+		&settings.NotificationsEnabled, &settings.EmailNotifications,
+		&settings.Language, &settings.Theme,
 	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	return user, nil
+	return settings, nil
+}
+
+// ScheduleDeletion marks the account to be purged at deleteAt.
+func (r *UserRepository) ScheduleDeletion(id int64, deleteAt time.Time, keepReviews bool) (*models.User, error) {
+	return scanUser(r.db.QueryRow(`
+		UPDATE users SET deletion_scheduled_at = $1, keep_reviews_on_deletion = $2,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+		RETURNING `+userColumns, deleteAt, keepReviews, id))
+}
+
+func (r *UserRepository) CancelDeletion(id int64) error {
+	_, err := r.db.Exec(`
+		UPDATE users SET deletion_scheduled_at = NULL, keep_reviews_on_deletion = FALSE,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+	`, id)
+	return err
+}
+
+// PurgeDeletedUsers removes the accounts whose grace period is over. Rows
+// referencing them are removed by ON DELETE CASCADE, except the reviews of
+// users who chose to keep them, which are detached first.
+func (r *UserRepository) PurgeDeletedUsers() (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE reviews SET user_id = NULL
+		WHERE user_id IN (
+			SELECT id FROM users
+			WHERE deletion_scheduled_at <= CURRENT_TIMESTAMP AND keep_reviews_on_deletion
+		)
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(`DELETE FROM users WHERE deletion_scheduled_at <= CURRENT_TIMESTAMP`)
+	if err != nil {
+		return 0, err
+	}
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, tx.Commit()
 }
 
 func (r *UserRepository) UpdatePassword(id int64, passwordHash string) error {
@@ -106,33 +249,88 @@ func (r *UserRepository) UpdatePassword(id int64, passwordHash string) error {
 	return err
 }
 
+// UpdateEmail replaces the user's address. It was confirmed through a link
+// sent to it, so the account stays verified.
+func (r *UserRepository) UpdateEmail(id int64, email string) error {
+	_, err := r.db.Exec(`
+		UPDATE users SET email = $1, email_verified_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+	`, email, id)
+	return err
+}
+
+// IsUniqueViolation reports whether err was caused by a UNIQUE constraint,
+// e.g. an email that is already taken.
+func IsUniqueViolation(err error) bool {
+	pqErr, ok := err.(*pq.Error)
+	return ok && pqErr.Code == "23505"
+}
+
 func (r *UserRepository) CreatePasswordResetToken(userID int64, token string, expiresAt time.Time) error {
 	_, err := r.db.Exec(`
-		INSERT INTO password_reset_tokens (user_id, token, expires_at)
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
 		VALUES ($1, $2, $3)
-	`, userID, token, expiresAt)
+	`, userID, hashToken(token), expiresAt)
 	return err
 }
 
+// FindPasswordResetToken returns the user of a valid reset token without
+// using it up.
 func (r *UserRepository) FindPasswordResetToken(token string) (int64, error) {
 	var userID int64
 	err := r.db.QueryRow(`
-		SELECT user_id FROM password_reset_tokens 
-		WHERE token = $1 AND expires_at > CURRENT_TIMESTAMP AND used = FALSE
-	`, token).Scan(&userID)
+		SELECT user_id FROM password_reset_tokens
+		WHERE token_hash = $1 AND used = FALSE AND expires_at > CURRENT_TIMESTAMP
+	`, hashToken(token)).Scan(&userID)
+	return userID, err
+}
+
+// ConsumePasswordResetToken marks a valid reset token as used and returns
+// its user, so that concurrent requests cannot both redeem it.
+func (r *UserRepository) ConsumePasswordResetToken(token string) (int64, error) {
+	var userID int64
+	err := r.db.QueryRow(`
+		UPDATE password_reset_tokens SET used = TRUE
+		WHERE token_hash = $1 AND used = FALSE AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id
+	`, hashToken(token)).Scan(&userID)
 	return userID, err
 }
 
-func (r *UserRepository) MarkPasswordResetTokenUsed(token string) error {
-	_, err := r.db.Exec(`UPDATE password_reset_tokens SET used = TRUE WHERE token = $1`, token)
+// CreateMagicLinkToken stores a login link token. With a device token the
+// link only works for the client holding it.
+func (r *UserRepository) CreateMagicLinkToken(userID int64, token, deviceToken, ipAddress string, expiresAt time.Time) error {
+	var deviceHash sql.NullString
+	if deviceToken != "" {
+		deviceHash = sql.NullString{String: hashToken(deviceToken), Valid: true}
+	}
+
+	_, err := r.db.Exec(`
+		INSERT INTO magic_link_tokens (user_id, token_hash, device_hash, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, userID, hashToken(token), deviceHash, ipAddress, expiresAt)
 	return err
 }
 
-func (r *UserRepository) SaveRefreshToken(userID int64, token string, expiresAt time.Time) error {
+// ConsumeMagicLinkToken marks a valid login link token as used and returns
+// its user. A wrong device token leaves the link unused, so it still works
+// on the client that requested it.
+func (r *UserRepository) ConsumeMagicLinkToken(token, deviceToken string) (int64, error) {
+	var userID int64
+	err := r.db.QueryRow(`
+		UPDATE magic_link_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+			AND (device_hash IS NULL OR device_hash = $2)
+		RETURNING user_id
+	`, hashToken(token), hashToken(deviceToken)).Scan(&userID)
+	return userID, err
+}
+
+func (r *UserRepository) SaveRefreshToken(userID int64, token, familyID string, client *models.ClientInfo, expiresAt time.Time) error {
 	_, err := r.db.Exec(`
-		INSERT INTO refresh_tokens (user_id, token, expires_at)
-		VALUES ($1, $2, $3)
-	`, userID, token, expiresAt)
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, user_agent, ip_address, last_used_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, $6)
+	`, userID, hashToken(token), familyID, client.UserAgent, client.IPAddress, expiresAt)
 	return err
 }
 
@@ -140,18 +338,139 @@ func (r *UserRepository) FindRefreshToken(token string) (int64, error) {
 	var userID int64
 	err := r.db.QueryRow(`
 		SELECT user_id FROM refresh_tokens 
-		WHERE token = $1 AND expires_at > CURRENT_TIMESTAMP AND revoked = FALSE
-	`, token).Scan(&userID)
+		WHERE token_hash = $1 AND expires_at > CURRENT_TIMESTAMP AND revoked = FALSE
+	`, hashToken(token)).Scan(&userID)
 	return userID, err
 }
 
+// FindRefreshTokenRecord returns a refresh token regardless of whether it
+// is still usable.
+func (r *UserRepository) FindRefreshTokenRecord(token string) (*models.RefreshToken, error) {
+	rt := &models.RefreshToken{}
+	err := r.db.QueryRow(`
+		SELECT id, user_id, family_id, expires_at, revoked, created_at
+		FROM refresh_tokens WHERE token_hash = $1
+	`, hashToken(token)).Scan(&rt.ID, &rt.UserID, &rt.FamilyID, &rt.ExpiresAt, &rt.Revoked, &rt.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+// RotateRefreshToken atomically revokes oldToken and stores newToken in the
+// same family. It returns sql.ErrNoRows if oldToken is unknown, expired or
+// already revoked, so concurrent rotations of one token cannot both succeed.
+func (r *UserRepository) RotateRefreshToken(oldToken, newToken string, client *models.ClientInfo, expiresAt time.Time) (int64, string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	var userID int64
+	var familyID string
+	err = tx.QueryRow(`
+		UPDATE refresh_tokens SET revoked = TRUE, revoked_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND expires_at > CURRENT_TIMESTAMP AND revoked = FALSE
+		RETURNING user_id, family_id
+	`, hashToken(oldToken)).Scan(&userID, &familyID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO refresh_tokens (user_id, token_hash, family_id, user_agent, ip_address, last_used_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, CURRENT_TIMESTAMP, $6)
+	`, userID, hashToken(newToken), familyID, client.UserAgent, client.IPAddress, expiresAt)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return userID, familyID, tx.Commit()
+}
+
+// RevokeRefreshTokenFamily revokes every active token of a family and
+// reports how many were still active.
+func (r *UserRepository) RevokeRefreshTokenFamily(familyID string) (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE refresh_tokens SET revoked = TRUE, revoked_at = CURRENT_TIMESTAMP
+		WHERE family_id = $1 AND revoked = FALSE
+	`, familyID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// FindSessions returns the active sessions of a user, most recently used first.
+func (r *UserRepository) FindSessions(userID int64) ([]*models.Session, error) {
+	rows, err := r.db.Query(`
+		SELECT rt.id, rt.family_id, rt.user_agent, rt.ip_address,
+			(SELECT MIN(f.created_at) FROM refresh_tokens f WHERE f.family_id = rt.family_id),
+			rt.last_used_at, rt.expires_at
+		FROM refresh_tokens rt
+		WHERE rt.user_id = $1 AND rt.revoked = FALSE AND rt.expires_at > CURRENT_TIMESTAMP
+		ORDER BY rt.last_used_at DESC NULLS LAST
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*models.Session
+	for rows.Next() {
+		session := &models.Session{}
+		err := rows.Scan(
+			&session.ID, &session.FamilyID, &session.UserAgent, &session.IPAddress,
+			&session.StartedAt, &session.LastUsedAt, &session.ExpiresAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// RevokeSession ends the session identified by the id of its active refresh
+// token and returns its family ID. It returns sql.ErrNoRows if the session
+// does not belong to the user.
+func (r *UserRepository) RevokeSession(userID, sessionID int64) (string, error) {
+	var familyID string
+	err := r.db.QueryRow(`
+		SELECT family_id FROM refresh_tokens
+		WHERE id = $1 AND user_id = $2 AND revoked = FALSE
+	`, sessionID, userID).Scan(&familyID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = r.RevokeRefreshTokenFamily(familyID)
+	return familyID, err
+}
+
 func (r *UserRepository) RevokeRefreshToken(token string) error {
-	_, err := r.db.Exec(`UPDATE refresh_tokens SET revoked = TRUE WHERE token = $1`, token)
+	_, err := r.db.Exec(`
+		UPDATE refresh_tokens SET revoked = TRUE, revoked_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND revoked = FALSE
+	`, hashToken(token))
 	return err
 }
 
-func (r *UserRepository) RevokeAllUserRefreshTokens(userID int64) error {
-	_, err := r.db.Exec(`UPDATE refresh_tokens SET revoked = TRUE WHERE user_id = $1`, userID)
+// RevokeOtherRefreshTokens ends every session of the user except the one
+// identified by keepFamilyID.
+func (r *UserRepository) RevokeOtherRefreshTokens(userID int64, keepFamilyID string) error {
+	_, err := r.db.Exec(`
+		UPDATE refresh_tokens SET revoked = TRUE, revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND family_id <> $2 AND revoked = FALSE
+	`, userID, keepFamilyID)
 	return err
 }
 
+func (r *UserRepository) RevokeAllUserRefreshTokens(userID int64) error {
+	_, err := r.db.Exec(`
+		UPDATE refresh_tokens SET revoked = TRUE, revoked_at = CURRENT_TIMESTAMP
+		WHERE user_id = $1 AND revoked = FALSE
+	`, userID)
+	return err
+}
@@ -0,0 +1,248 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"sensory-navigator/models"
+	"sensory-navigator/repository"
+)
+
+const (
+	purposeEmailChange     = "email_change"
+	purposeEmailChangeUndo = "email_change_undo"
+)
+
+var (
+	ErrEmailUnchanged          = errors.New("new email must differ from the current one")
+	ErrInvalidEmailChangeToken = errors.New("invalid or expired email change token")
+	ErrAccountPendingDeletion  = errors.New("account is scheduled for deletion; restore it to sign in")
+)
+
+// ChangePassword replaces the password of a signed-in user, ends all
+// other sessions and revokes the user's API keys. All access tokens are
+// revoked; the current session, identified by sessionID, keeps its refresh
+// token and gets a new access token.
+func (s *AuthService) ChangePassword(userID int64, sessionID string, req *models.ChangePasswordRequest, client *models.ClientInfo) (string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.checkCurrentPassword(user, req.CurrentPassword, client); err != nil {
+		return "", err
+	}
+	if err := s.passwords.Validate("new_password", req.NewPassword, user.Email, user.Username); err != nil {
+		return "", err
+	}
+
+	hashedPassword, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return "", err
+	}
+	if err := s.userRepo.UpdatePassword(userID, hashedPassword); err != nil {
+		return "", err
+	}
+
+	if err := s.userRepo.RevokeOtherRefreshTokens(userID, sessionID); err != nil {
+		return "", err
+	}
+	if err := s.RevokeAccessTokens(userID); err != nil {
+		return "", err
+	}
+	if err := s.apiKeyRepo.RevokeAllForUser(userID); err != nil {
+		return "", err
+	}
+
+	s.audit(models.EventPasswordChanged, userID, client, nil)
+
+	// Reload for the new token generation
+	user, err = s.userRepo.FindByID(userID)
+	if err != nil {
+		return "", err
+	}
+	return s.generateAccessToken(user, sessionID)
+}
+
+// RequestEmailChange sends a confirmation link to the new address. The
+// email is only changed once that link is opened.
+func (s *AuthService) RequestEmailChange(userID int64, req *models.ChangeEmailRequest, client *models.ClientInfo) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkCurrentPassword(user, req.Password, client); err != nil {
+		return err
+	}
+
+	if strings.EqualFold(req.NewEmail, user.Email) {
+		return ErrEmailUnchanged
+	}
+	if existing, _ := s.userRepo.FindByEmail(req.NewEmail); existing != nil {
+		return ErrEmailTaken
+	}
+
+	// The token is bound to the current address, so it stops working once
+	// the email has changed
+	token, err := s.signActionToken(&actionClaims{
+		UserID:   user.ID,
+		Email:    user.Email,
+		Purpose:  purposeEmailChange,
+		NewEmail: req.NewEmail,
+	}, s.authConfig.EmailChangeExpiry)
+	if err != nil {
+		return err
+	}
+
+	return s.sendEmailTo(user, req.NewEmail, "email_change", map[string]string{
+		"Username": user.Username,
+		"NewEmail": req.NewEmail,
+		"Link":     s.appLink("/confirm-email-change", token),
+	})
+}
+
+// ConfirmEmailChange switches the account to the new address. The old
+// address is notified first with a link to undo the change, so an address
+// is never replaced silently.
+func (s *AuthService) ConfirmEmailChange(token string, client *models.ClientInfo) (*models.User, error) {
+	claims, err := s.parseActionToken(token, purposeEmailChange)
+	if err != nil {
+		return nil, ErrInvalidEmailChangeToken
+	}
+
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err != nil || user.Email != claims.Email {
+		return nil, ErrInvalidEmailChangeToken
+	}
+
+	undoToken, err := s.signActionToken(&actionClaims{
+		UserID:   user.ID,
+		Email:    claims.NewEmail,
+		Purpose:  purposeEmailChangeUndo,
+		NewEmail: claims.Email,
+	}, s.authConfig.EmailChangeUndoExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.sendEmail(user, "email_changed", map[string]string{
+		"Username": user.Username,
+		"NewEmail": claims.NewEmail,
+		"Link":     s.appLink("/undo-email-change", undoToken),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.updateEmail(user.ID, claims.NewEmail); err != nil {
+		return nil, err
+	}
+
+	s.audit(models.EventEmailChanged, user.ID, client, map[string]interface{}{
+		"old_email": claims.Email,
+		"new_email": claims.NewEmail,
+	})
+
+	return s.userRepo.FindByID(user.ID)
+}
+
+// UndoEmailChange restores the previous address from the link sent to it
+// and ends all sessions and API keys, since the change may have been made
+// by someone who took over the account.
+func (s *AuthService) UndoEmailChange(token string, client *models.ClientInfo) error {
+	claims, err := s.parseActionToken(token, purposeEmailChangeUndo)
+	if err != nil {
+		return ErrInvalidEmailChangeToken
+	}
+
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err != nil || user.Email != claims.Email {
+		return ErrInvalidEmailChangeToken
+	}
+
+	if err := s.updateEmail(user.ID, claims.NewEmail); err != nil {
+		return err
+	}
+
+	if err := s.LogoutAll(user.ID); err != nil {
+		return err
+	}
+
+	s.audit(models.EventEmailChangeUndone, user.ID, client, map[string]interface{}{
+		"restored_email": claims.NewEmail,
+		"undone_email":   claims.Email,
+	})
+	return nil
+}
+
+// DeleteAccount schedules the account for deletion after the grace period
+// and signs it out everywhere. Signing in through RestoreAccount before
+// the period ends cancels the deletion.
+func (s *AuthService) DeleteAccount(userID int64, req *models.DeleteAccountRequest, client *models.ClientInfo) (*models.User, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkCurrentPassword(user, req.Password, client); err != nil {
+		return nil, err
+	}
+
+	deleteAt := time.Now().Add(s.authConfig.AccountDeletionGracePeriod)
+	user, err = s.userRepo.ScheduleDeletion(userID, deleteAt, req.KeepReviews)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.LogoutAll(userID); err != nil {
+		return nil, err
+	}
+
+	s.audit(models.EventAccountDeletionScheduled, userID, client, map[string]interface{}{
+		"delete_at":    deleteAt,
+		"keep_reviews": req.KeepReviews,
+	})
+
+	return user, nil
+}
+
+// SendAccountDeletionEmail tells the user when the account will be purged
+// and how to restore it.
+func (s *AuthService) SendAccountDeletionEmail(user *models.User) error {
+	return s.sendEmail(user, "account_deletion", map[string]string{
+		"Username": user.Username,
+		"Date":     user.DeletionScheduledAt.Time.Format("2006-01-02"),
+		"Link":     s.mailConfig.AppURL + "/restore-account",
+	})
+}
+
+// RestoreAccount cancels a scheduled deletion and signs the user in.
+func (s *AuthService) RestoreAccount(req *models.LoginRequest, client *models.ClientInfo) (*LoginResult, error) {
+	user, err := s.authenticate(req, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.DeletionScheduledAt.Valid {
+		if err := s.userRepo.CancelDeletion(user.ID); err != nil {
+			return nil, err
+		}
+		user.DeletionScheduledAt.Valid = false
+
+		s.audit(models.EventAccountRestored, user.ID, client, nil)
+	}
+
+	return s.LoginAuthenticatedUser(user, client)
+}
+
+// PurgeDeletedAccounts removes accounts whose grace period has ended.
+func (s *AuthService) PurgeDeletedAccounts() (int64, error) {
+	return s.userRepo.PurgeDeletedUsers()
+}
+
+func (s *AuthService) updateEmail(userID int64, email string) error {
+	err := s.userRepo.UpdateEmail(userID, email)
+	if repository.IsUniqueViolation(err) {
+		return ErrEmailTaken
+	}
+	return err
+}
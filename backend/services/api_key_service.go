@@ -0,0 +1,236 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"sensory-navigator/config"
+	"sensory-navigator/models"
+	"sensory-navigator/repository"
+)
+
+const (
+	apiKeyPrefix      = "sn_"
+	maxAPIKeysPerUser = 20
+	// touchInterval is how often the last use of a key is written; the
+	// repository enforces the same interval between instances
+	touchInterval = time.Minute
+)
+
+var (
+	ErrTooManyAPIKeys = errors.New("too many API keys, revoke an unused one first")
+	ErrInvalidAPIKey  = errors.New("invalid API key")
+	ErrInvalidScope   = errors.New("requested scope is not granted to this client")
+)
+
+// ClientToken is the response of the OAuth2 client credentials grant.
+type ClientToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// APIKeyService manages personal API keys. A key is used directly in the
+// X-API-Key header, or as client_id and client_secret to obtain a short
+// lived access token through the client credentials grant.
+type APIKeyService struct {
+	apiKeyRepo *repository.APIKeyRepository
+	config     *config.JWTConfig
+	keys       *KeySet
+	revocation *TokenRevocationCache
+}
+
+func NewAPIKeyService(apiKeyRepo *repository.APIKeyRepository, jwtConfig *config.JWTConfig, keys *KeySet, revocation *TokenRevocationCache) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo: apiKeyRepo,
+		config:     jwtConfig,
+		keys:       keys,
+		revocation: revocation,
+	}
+}
+
+// Create issues a new key. The full key is only part of this response.
+func (s *APIKeyService) Create(userID int64, req *models.CreateAPIKeyRequest) (*models.APIKeyResponse, error) {
+	count, err := s.apiKeyRepo.CountActive(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxAPIKeysPerUser {
+		return nil, ErrTooManyAPIKeys
+	}
+
+	keyID, err := randomHex(12)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := s.generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays != nil {
+		t := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	key, err := s.apiKeyRepo.Create(userID, req.Name, keyID, secret, req.Scopes, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := key.ToResponse()
+	resp.Key = apiKeyPrefix + keyID + "_" + secret
+	return &resp, nil
+}
+
+func (s *APIKeyService) List(userID int64) ([]models.APIKeyResponse, error) {
+	keys, err := s.apiKeyRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]models.APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, key.ToResponse())
+	}
+	return response, nil
+}
+
+func (s *APIKeyService) Revoke(userID, id int64) error {
+	return s.apiKeyRepo.Revoke(userID, id)
+}
+
+// Authenticate checks a key from the X-API-Key header and records its use.
+func (s *APIKeyService) Authenticate(apiKey, ip string) (*models.APIKey, error) {
+	keyID, secret, ok := strings.Cut(strings.TrimPrefix(apiKey, apiKeyPrefix), "_")
+	if !ok || !strings.HasPrefix(apiKey, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	return s.authenticateClient(keyID, secret, ip)
+}
+
+// IssueClientToken implements the OAuth2 client credentials grant. scope
+// is a space-separated subset of the key's scopes; empty means all of them.
+func (s *APIKeyService) IssueClientToken(clientID, clientSecret, scope, ip string) (*ClientToken, error) {
+	key, err := s.authenticateClient(clientID, clientSecret, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		scopes = key.Scopes
+	}
+	for _, requested := range scopes {
+		if !key.HasScope(requested) {
+			return nil, ErrInvalidScope
+		}
+	}
+
+	// Revoking the owner's access tokens revokes client tokens as well
+	generation, err := s.revocation.Generation(key.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	granted := strings.Join(scopes, " ")
+	claims := &Claims{
+		UserID:     key.UserID,
+		Scope:      granted,
+		ClientID:   key.KeyID,
+		Generation: generation,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.config.AccessExpiry)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token, err := s.keys.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClientToken{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.config.AccessExpiry.Seconds()),
+		Scope:       granted,
+	}, nil
+}
+
+// CheckClientToken makes sure the key a client token was issued for has
+// not been revoked since, and records its use.
+func (s *APIKeyService) CheckClientToken(claims *Claims, ip string) error {
+	key, err := s.apiKeyRepo.FindActive(claims.ClientID)
+	if err == sql.ErrNoRows || (err == nil && key.UserID != claims.UserID) {
+		return ErrInvalidAPIKey
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.touch(key, ip)
+}
+
+func (s *APIKeyService) authenticateClient(keyID, secret, ip string) (*models.APIKey, error) {
+	key, err := s.apiKeyRepo.FindActive(keyID)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if err := s.touch(key, ip); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// touch records a use of the key unless one was recorded less than
+// touchInterval ago, so busy clients do not write on every request.
+func (s *APIKeyService) touch(key *models.APIKey, ip string) error {
+	if key.LastUsedAt.Valid && time.Since(key.LastUsedAt.Time) < touchInterval {
+		return nil
+	}
+	return s.apiKeyRepo.TouchLastUsed(key.ID, ip)
+}
+
+func (s *APIKeyService) generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSecret matches the SHA-256 digest the repository stores.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"sensory-navigator/config"
+	"sensory-navigator/models"
+	"sensory-navigator/repository"
+)
+
+func TestAPIKeyTouch(t *testing.T) {
+	tests := []struct {
+		name      string
+		lastUsed  sql.NullTime
+		wantWrite bool
+	}{
+		{name: "never used", wantWrite: true},
+		{name: "used just now", lastUsed: sql.NullTime{Time: time.Now().Add(-10 * time.Second), Valid: true}},
+		{name: "used a while ago", lastUsed: sql.NullTime{Time: time.Now().Add(-2 * time.Minute), Valid: true}, wantWrite: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB()
+			s := &APIKeyService{apiKeyRepo: repository.NewAPIKeyRepository(db)}
+
+			if err := s.touch(&models.APIKey{ID: 1, LastUsedAt: tt.lastUsed}, "203.0.113.1"); err != nil {
+				t.Fatalf("touch() error = %v", err)
+			}
+			if wrote := fake.ran("UPDATE api_keys") > 0; wrote != tt.wantWrite {
+				t.Errorf("last use written = %v, want %v", wrote, tt.wantWrite)
+			}
+		})
+	}
+}
+
+var userColumns = []string{"id", "email", "password_hash", "username", "avatar_url", "birth_date",
+	"email_verified_at", "role", "deletion_scheduled_at", "token_generation", "created_at", "updated_at"}
+
+func userRow(t *testing.T, password string) []driver.Value {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	return []driver.Value{int64(42), "user@example.com", string(hash), "user", nil, nil, nil, models.RoleUser, nil, int64(0), now, now}
+}
+
+func TestChangePasswordRevokesAPIKeys(t *testing.T) {
+	tests := []struct {
+		name       string
+		current    string
+		wantErr    error
+		wantRevoke bool
+	}{
+		{name: "correct password", current: "old-password", wantRevoke: true},
+		{name: "wrong password", current: "guess", wantErr: ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB()
+			fake.onQuery("FROM users WHERE id = $1", userColumns, userRow(t, "old-password"))
+			s := newRevocationTestService(t, newMemoryRevocationStore())
+			s.userRepo = repository.NewUserRepository(db)
+			s.apiKeyRepo = repository.NewAPIKeyRepository(db)
+			s.auditor = repository.NewAuditEventRepository(db)
+			s.throttle = newTestLoginThrottle()
+			s.hasher = newTestHasher(t, testBcryptConfig)
+			s.passwords, _ = NewPasswordPolicy(&config.PasswordPolicyConfig{MinLength: 8})
+
+			req := &models.ChangePasswordRequest{CurrentPassword: tt.current, NewPassword: "new-password"}
+			_, err := s.ChangePassword(42, "session", req, &models.ClientInfo{IPAddress: "203.0.113.1"})
+			if err != tt.wantErr {
+				t.Fatalf("ChangePassword() error = %v, want %v", err, tt.wantErr)
+			}
+			if revoked := fake.ran("UPDATE api_keys SET revoked_at") > 0; revoked != tt.wantRevoke {
+				t.Errorf("API keys revoked = %v, want %v", revoked, tt.wantRevoke)
+			}
+		})
+	}
+}
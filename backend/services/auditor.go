@@ -0,0 +1,43 @@
+package services
+
+import (
+	"log"
+
+	"sensory-navigator/models"
+)
+
+// Auditor records security-relevant events in the audit log.
+type Auditor interface {
+	Record(event *models.AuditEvent) error
+}
+
+// NewAuditEvent describes an event done by actorID to targetID from the
+// given client. A zero ID or a nil client leaves the field empty.
+func NewAuditEvent(eventType string, actorID, targetID int64, client *models.ClientInfo, details map[string]interface{}) *models.AuditEvent {
+	event := &models.AuditEvent{EventType: eventType, Details: details}
+	if actorID != 0 {
+		event.ActorID = &actorID
+	}
+	if targetID != 0 {
+		event.TargetID = &targetID
+	}
+	if client != nil {
+		event.IPAddress = client.IPAddress
+		event.UserAgent = client.UserAgent
+	}
+	return event
+}
+
+// audit records an event a user did to their own account.
+func (s *AuthService) audit(eventType string, userID int64, client *models.ClientInfo, details map[string]interface{}) {
+	s.record(NewAuditEvent(eventType, userID, userID, client, details))
+}
+
+// record adds an event to the audit log. It is called once the event has
+// taken effect, which a failure to record it cannot undo, so a failure is
+// only logged.
+func (s *AuthService) record(event *models.AuditEvent) {
+	if err := s.auditor.Record(event); err != nil {
+		log.Printf("Failed to record %s: %v", event.EventType, err)
+	}
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"sensory-navigator/models"
+)
+
+// recordingAuditor keeps events in memory and fails with err when set.
+type recordingAuditor struct {
+	events []*models.AuditEvent
+	err    error
+}
+
+func (a *recordingAuditor) Record(event *models.AuditEvent) error {
+	if a.err != nil {
+		return a.err
+	}
+	a.events = append(a.events, event)
+	return nil
+}
+
+func TestNewAuditEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		actorID    int64
+		targetID   int64
+		client     *models.ClientInfo
+		wantActor  bool
+		wantTarget bool
+		wantIP     string
+		wantAgent  string
+	}{
+		{name: "own action", actorID: 1, targetID: 1, client: &models.ClientInfo{IPAddress: "203.0.113.1", UserAgent: "curl"}, wantActor: true, wantTarget: true, wantIP: "203.0.113.1", wantAgent: "curl"},
+		{name: "unknown actor", targetID: 1, client: &models.ClientInfo{IPAddress: "203.0.113.1"}, wantTarget: true, wantIP: "203.0.113.1"},
+		{name: "unknown account", client: &models.ClientInfo{}},
+		{name: "no client", actorID: 2, targetID: 1, wantActor: true, wantTarget: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := NewAuditEvent(models.EventLoginFailed, tt.actorID, tt.targetID, tt.client, nil)
+
+			if (event.ActorID != nil) != tt.wantActor || (event.ActorID != nil && *event.ActorID != tt.actorID) {
+				t.Errorf("ActorID = %v, want %d (set %v)", event.ActorID, tt.actorID, tt.wantActor)
+			}
+			if (event.TargetID != nil) != tt.wantTarget || (event.TargetID != nil && *event.TargetID != tt.targetID) {
+				t.Errorf("TargetID = %v, want %d (set %v)", event.TargetID, tt.targetID, tt.wantTarget)
+			}
+			if event.IPAddress != tt.wantIP || event.UserAgent != tt.wantAgent {
+				t.Errorf("client = (%q, %q), want (%q, %q)", event.IPAddress, event.UserAgent, tt.wantIP, tt.wantAgent)
+			}
+		})
+	}
+}
+
+func TestLoginFailedRecordsEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		user       *models.User
+		auditErr   error
+		wantTarget bool
+	}{
+		{name: "known account", user: &models.User{ID: 42, Email: "user@example.com"}, wantTarget: true},
+		{name: "unknown email", user: nil},
+		{name: "audit log unavailable", user: &models.User{ID: 42, Email: "user@example.com"}, auditErr: errors.New("connection reset")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditor := &recordingAuditor{err: tt.auditErr}
+			s := &AuthService{auditor: auditor}
+
+			err := s.loginFailed("user@example.com", tt.user, &models.ClientInfo{IPAddress: "203.0.113.1"})
+			if err != ErrInvalidCredentials {
+				t.Fatalf("loginFailed() error = %v, want %v", err, ErrInvalidCredentials)
+			}
+			if tt.auditErr != nil {
+				return
+			}
+
+			if len(auditor.events) != 1 {
+				t.Fatalf("recorded %d events, want 1", len(auditor.events))
+			}
+			event := auditor.events[0]
+			if event.EventType != models.EventLoginFailed || event.ActorID != nil {
+				t.Errorf("event = %s by %v, want %s by nobody", event.EventType, event.ActorID, models.EventLoginFailed)
+			}
+			if (event.TargetID != nil) != tt.wantTarget {
+				t.Errorf("TargetID = %v, want set %v", event.TargetID, tt.wantTarget)
+			}
+			if event.Details["email"] != "user@example.com" {
+				t.Errorf("details = %v, want the email", event.Details)
+			}
+		})
+	}
+}
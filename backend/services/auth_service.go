@@ -1,22 +1,39 @@
 package services
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
 	"errors"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 
 	"sensory-navigator/config"
+	"sensory-navigator/mailer"
 	"sensory-navigator/models"
 	"sensory-navigator/repository"
 )
 
 type AuthService struct {
-	userRepo *repository.UserRepository
-	config   *config.JWTConfig
+	userRepo      *repository.UserRepository
+	twoFactorRepo *repository.TwoFactorRepository
+	apiKeyRepo    *repository.APIKeyRepository
+	auditor       Auditor
+	config        *config.JWTConfig
+	keys          *KeySet
+	revocation    *TokenRevocationCache
+	throttle      *LoginThrottle
+	limiter       *RateLimiter
+	passwords     *PasswordPolicy
+	hasher        *PasswordHasher
+	authConfig    *config.AuthConfig
+	mailer        mailer.Mailer
+	mailConfig    *config.MailConfig
 }
 
 type TokenPair struct {
@@ -26,71 +43,362 @@ type TokenPair struct {
 }
 
 type Claims struct {
-	UserID int64 `json:"user_id"`
+	UserID int64  `json:"user_id"`
+	Role   string `json:"role,omitempty"`
+	// Scope and ClientID are set on tokens issued to API clients, which
+	// act on behalf of UserID with the listed scopes only
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	// SessionID is the refresh token family the access token was issued for
+	SessionID string `json:"sid,omitempty"`
+	// Generation must match the user's current token generation; bumping
+	// it revokes all access tokens of the user
+	Generation int64 `json:"gen,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func NewAuthService(userRepo *repository.UserRepository, jwtConfig *config.JWTConfig) *AuthService {
+// actionClaims are carried by single-purpose tokens sent in emails.
+// Email binds the token to the address it was sent to.
+type actionClaims struct {
+	UserID  int64  `json:"user_id"`
+	Email   string `json:"email"`
+	Purpose string `json:"purpose"`
+	// NewEmail is the other address involved in an email change
+	NewEmail string `json:"new_email,omitempty"`
+	jwt.RegisteredClaims
+}
+
+const (
+	purposeEmailVerification = "email_verification"
+	// purposeReauthentication tokens stand in for the password of accounts
+	// that have none
+	purposeReauthentication = "reauthentication"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrEmailTaken         = errors.New("user with this email already exists")
+	ErrTokenRevoked       = errors.New("token has been revoked")
+	ErrInvalidResetToken  = errors.New("invalid or expired reset token")
+)
+
+func NewAuthService(userRepo *repository.UserRepository, twoFactorRepo *repository.TwoFactorRepository, apiKeyRepo *repository.APIKeyRepository, auditor Auditor, jwtConfig *config.JWTConfig, keys *KeySet, revocation *TokenRevocationCache, throttle *LoginThrottle, limiter *RateLimiter, passwords *PasswordPolicy, hasher *PasswordHasher, authConfig *config.AuthConfig, m mailer.Mailer, mailConfig *config.MailConfig) *AuthService {
 	return &AuthService{
-		userRepo: userRepo,
-		config:   jwtConfig,
+		userRepo:      userRepo,
+		twoFactorRepo: twoFactorRepo,
+		apiKeyRepo:    apiKeyRepo,
+		auditor:       auditor,
+		config:        jwtConfig,
+		keys:          keys,
+		revocation:    revocation,
+		throttle:      throttle,
+		limiter:       limiter,
+		passwords:     passwords,
+		hasher:        hasher,
+		authConfig:    authConfig,
+		mailer:        m,
+		mailConfig:    mailConfig,
 	}
 }
 
-func (s *AuthService) Register(req *models.RegisterRequest) (*models.User, error) {
+func (s *AuthService) Register(req *models.RegisterRequest, client *models.ClientInfo) (*models.User, error) {
 	// Check if user already exists
 	existingUser, _ := s.userRepo.FindByEmail(req.Email)
 	if existingUser != nil {
-		return nil, errors.New("user with this email already exists")
+		return nil, ErrEmailTaken
+	}
+
+	if err := s.passwords.Validate("password", req.Password, req.Email, req.Username); err != nil {
+		return nil, err
 	}
 
 	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create user; a concurrent registration may still take the email
+	user, err := s.userRepo.Create(req.Email, hashedPassword, req.Username)
+	if repository.IsUniqueViolation(err) {
+		return nil, ErrEmailTaken
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// Create user
-	return s.userRepo.Create(req.Email, string(hashedPassword), req.Username)
+	s.audit(models.EventRegistered, user.ID, client, nil)
+	return user, nil
 }
 
-func (s *AuthService) Login(req *models.LoginRequest) (*models.User, *TokenPair, error) {
+// BootstrapAdmins grants the admin role to the accounts listed in
+// ADMIN_EMAILS. Addresses without an account are returned so the caller can
+// report them; they are picked up on the next start after registration.
+func (s *AuthService) BootstrapAdmins() ([]string, error) {
+	var missing []string
+	for _, email := range s.authConfig.AdminEmails {
+		found, err := s.userRepo.UpdateRoleByEmail(email, models.RoleAdmin)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			missing = append(missing, email)
+		}
+	}
+	return missing, nil
+}
+
+func (s *AuthService) Login(req *models.LoginRequest, client *models.ClientInfo) (*LoginResult, error) {
+	user, err := s.authenticate(req, client)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.LoginAuthenticatedUser(user, client)
+}
+
+// authenticate checks an email and password, counting failures towards
+// the login throttle.
+func (s *AuthService) authenticate(req *models.LoginRequest, client *models.ClientInfo) (*models.User, error) {
+	// Refuse attempts while the email or IP is delayed or locked
+	if err := s.throttle.Attempt(req.Email, client.IPAddress); err != nil {
+		return nil, err
+	}
+
 	// Find user
 	user, err := s.userRepo.FindByEmail(req.Email)
 	if err != nil {
-		return nil, nil, errors.New("invalid credentials")
+		return nil, s.loginFailed(req.Email, nil, client)
 	}
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		return nil, nil, errors.New("invalid credentials")
+	if !s.verifyCredential(user, req.Password) {
+		return nil, s.loginFailed(req.Email, user, client)
 	}
 
-	// Generate tokens
-	tokens, err := s.GenerateTokenPair(user.ID)
+	if err := s.throttle.RecordSuccess(req.Email, client.IPAddress); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// verifyPassword checks the user's password. A hash made with an older
+// algorithm or weaker parameters is replaced while the plain password is
+// at hand.
+func (s *AuthService) verifyPassword(user *models.User, password string) bool {
+	match, rehash := s.hasher.Verify(user.PasswordHash, password)
+	if !rehash {
+		return match
+	}
+
+	// On failure the old hash keeps working and the next login tries again
+	if hashedPassword, err := s.hasher.Hash(password); err == nil {
+		if s.userRepo.UpdatePassword(user.ID, hashedPassword) == nil {
+			user.PasswordHash = hashedPassword
+		}
+	}
+	return true
+}
+
+// LoginAuthenticatedUser finishes a login once the first factor (password
+// or an external identity provider) has been checked. Users with two-factor
+// authentication get a challenge instead of tokens.
+func (s *AuthService) LoginAuthenticatedUser(user *models.User, client *models.ClientInfo) (*LoginResult, error) {
+	if user.DeletionScheduledAt.Valid {
+		return nil, ErrAccountPendingDeletion
+	}
+
+	twoFactor, err := s.twoFactorRepo.IsEnabled(user.ID)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
+	}
+	if twoFactor {
+		challenge, err := s.generateActionToken(user, purposeLoginChallenge, s.authConfig.TwoFactorChallengeExpiry)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{User: user, ChallengeToken: challenge}, nil
 	}
 
-	return user, tokens, nil
+	return s.completeLogin(user, client)
 }
 
-func (s *AuthService) GenerateTokenPair(userID int64) (*TokenPair, error) {
-	// Generate access token
-	accessToken, err := s.generateAccessToken(userID)
+// completeLogin clears the failed attempt counter and issues tokens.
+func (s *AuthService) completeLogin(user *models.User, client *models.ClientInfo) (*LoginResult, error) {
+	if err := s.throttle.Reset(user.Email); err != nil {
+		return nil, err
+	}
+
+	// Generate tokens
+	tokens, err := s.GenerateTokenPair(user.ID, client)
 	if err != nil {
 		return nil, err
 	}
 
+	s.audit(models.EventLoginSucceeded, user.ID, client, nil)
+
+	return &LoginResult{User: user, Tokens: tokens}, nil
+}
+
+// loginFailed records a failed attempt, which the login throttle has
+// already counted, and returns the error shown to the client. Unknown
+// emails, for which user is nil, are counted too, so probing them is
+// throttled.
+func (s *AuthService) loginFailed(email string, user *models.User, client *models.ClientInfo) error {
+	var targetID int64
+	if user != nil {
+		targetID = user.ID
+	}
+	s.record(NewAuditEvent(models.EventLoginFailed, 0, targetID, client, map[string]interface{}{
+		"email": email,
+	}))
+
+	return ErrInvalidCredentials
+}
+
+// checkCurrentPassword confirms the password of a signed-in user before a
+// sensitive change. Failures count towards the login throttle, so a stolen
+// session cannot be used to guess the password.
+func (s *AuthService) checkCurrentPassword(user *models.User, password string, client *models.ClientInfo) error {
+	if err := s.throttle.Attempt(user.Email, client.IPAddress); err != nil {
+		return err
+	}
+	if !s.verifyCredential(user, password) {
+		return s.loginFailed(user.Email, user, client)
+	}
+	return s.throttle.RecordSuccess(user.Email, client.IPAddress)
+}
+
+// verifyCredential checks what the user signs in with: the password, or
+// for accounts without one a reauthentication token sent to their current
+// address.
+func (s *AuthService) verifyCredential(user *models.User, secret string) bool {
+	if user.PasswordHash != "" {
+		return s.verifyPassword(user, secret)
+	}
+	claims, err := s.parseActionToken(secret, purposeReauthentication)
+	return err == nil && claims.UserID == user.ID && claims.Email == user.Email
+}
+
+// RequestReauthentication emails a link with a code to an account created
+// through OIDC, which it sends instead of a password to sign in or to
+// confirm sensitive changes. Accounts with a password get nothing, and the
+// response does not tell them apart.
+func (s *AuthService) RequestReauthentication(email string, client *models.ClientInfo) error {
+	if err := s.limitMailRequest("reauth", email, client.IPAddress, s.authConfig.MailRequestEmailLimit, s.authConfig.MailRequestIPLimit); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil || user.PasswordHash != "" {
+		return nil
+	}
+
+	token, err := s.generateActionToken(user, purposeReauthentication, s.authConfig.ReauthenticationExpiry)
+	if err != nil {
+		return err
+	}
+
+	return s.sendEmail(user, "reauthentication", map[string]interface{}{
+		"Username": user.Username,
+		"Link":     s.appLink("/reauthenticate", token),
+		"Minutes":  int(s.authConfig.ReauthenticationExpiry.Minutes()),
+	})
+}
+
+func (s *AuthService) GenerateTokenPair(userID int64, client *models.ClientInfo) (*TokenPair, error) {
 	// Generate refresh token
 	refreshToken, err := s.generateRefreshToken()
 	if err != nil {
 		return nil, err
 	}
 
+	// Every login starts a new refresh token family
+	familyID, err := s.generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
 	// Save refresh token to database
 	expiresAt := time.Now().Add(s.config.RefreshExpiry)
-	if err := s.userRepo.SaveRefreshToken(userID, refreshToken, expiresAt); err != nil {
+	if err := s.userRepo.SaveRefreshToken(userID, refreshToken, familyID, client, expiresAt); err != nil {
+		return nil, err
+	}
+
+	return s.newTokenPair(userID, familyID, refreshToken)
+}
+
+func (s *AuthService) RefreshTokens(refreshToken string, client *models.ClientInfo) (*TokenPair, error) {
+	newRefreshToken, err := s.generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// Revoke old refresh token and store the new one in the same family
+	expiresAt := time.Now().Add(s.config.RefreshExpiry)
+	userID, familyID, err := s.userRepo.RotateRefreshToken(refreshToken, newRefreshToken, client, expiresAt)
+	if err == sql.ErrNoRows {
+		if err := s.detectRefreshTokenReuse(refreshToken, client); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("invalid refresh token")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(models.EventTokenRefreshed, userID, client, nil)
+
+	return s.newTokenPair(userID, familyID, newRefreshToken)
+}
+
+// detectRefreshTokenReuse revokes the whole family when an already revoked
+// token is presented again: either the legitimate client or an attacker
+// holds a stolen copy, and we cannot tell which one.
+func (s *AuthService) detectRefreshTokenReuse(refreshToken string, client *models.ClientInfo) error {
+	record, err := s.userRepo.FindRefreshTokenRecord(refreshToken)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !record.Revoked {
+		// Expired, not reused
+		return nil
+	}
+
+	revoked, err := s.userRepo.RevokeRefreshTokenFamily(record.FamilyID)
+	if err != nil {
+		return err
+	}
+	if revoked == 0 {
+		// The session was already ended, e.g. by logout
+		return nil
+	}
+
+	// Deny the stolen family's access tokens by their sid; the user's other
+	// sessions keep working
+	if err := s.revokeSessionAccessTokens(record.FamilyID); err != nil {
+		return err
+	}
+
+	s.record(NewAuditEvent(models.EventRefreshTokenReuse, 0, record.UserID, client, map[string]interface{}{
+		"family_id": record.FamilyID,
+		"token_id":  record.ID,
+	}))
+	return nil
+}
+
+func (s *AuthService) newTokenPair(userID int64, familyID, refreshToken string) (*TokenPair, error) {
+	// Look the user up so that role changes apply from the next refresh
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.generateAccessToken(user, familyID)
+	if err != nil {
 		return nil, err
 	}
 
@@ -101,37 +409,115 @@ func (s *AuthService) GenerateTokenPair(userID int64) (*TokenPair, error) {
 	}, nil
 }
 
-func (s *AuthService) RefreshTokens(refreshToken string) (*TokenPair, error) {
-	// Find refresh token in database
-	userID, err := s.userRepo.FindRefreshToken(refreshToken)
+// Logout ends the session the refresh token belongs to. Unknown tokens are
+// ignored so that logging out twice is harmless. When the client also
+// sends its access token, that token stops working immediately.
+func (s *AuthService) Logout(refreshToken, accessToken string, client *models.ClientInfo) error {
+	if err := s.userRepo.RevokeRefreshToken(refreshToken); err != nil {
+		return err
+	}
+
+	if accessToken == "" {
+		return nil
+	}
+	claims, err := s.ValidateAccessToken(accessToken)
+	if err != nil || claims.ID == "" {
+		// Already invalid
+		return nil
+	}
+	if err := s.revocation.RevokeToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+	s.audit(models.EventLogout, claims.UserID, client, nil)
+	return nil
+}
+
+// LogoutAll ends every session of the user, including access tokens that
+// have not expired yet, and revokes the user's API keys.
+func (s *AuthService) LogoutAll(userID int64) error {
+	if err := s.userRepo.RevokeAllUserRefreshTokens(userID); err != nil {
+		return err
+	}
+	if err := s.apiKeyRepo.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+	return s.RevokeAccessTokens(userID)
+}
+
+// RevokeSession ends one of the user's sessions, identified by the id of
+// its active refresh token. Access tokens already issued for it are denied
+// by their sid.
+func (s *AuthService) RevokeSession(userID, sessionID int64) error {
+	familyID, err := s.userRepo.RevokeSession(userID, sessionID)
 	if err != nil {
-		return nil, errors.New("invalid refresh token")
+		return err
 	}
+	return s.revokeSessionAccessTokens(familyID)
+}
 
-	// Revoke old refresh token
-	s.userRepo.RevokeRefreshToken(refreshToken)
+// revokeSessionAccessTokens denies the access tokens issued for a refresh
+// token family. They expire within AccessExpiry from now.
+func (s *AuthService) revokeSessionAccessTokens(familyID string) error {
+	return s.revocation.RevokeToken(familyID, time.Now().Add(s.config.AccessExpiry))
+}
 
-	// Generate new token pair
-	return s.GenerateTokenPair(userID)
+// RevokeAccessTokens invalidates all access tokens issued to the user so
+// far, e.g. so that a role change applies immediately. Refresh tokens stay
+// valid and yield tokens with the current state.
+func (s *AuthService) RevokeAccessTokens(userID int64) error {
+	return s.revocation.RevokeUser(userID)
 }
 
 func (s *AuthService) ValidateAccessToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(s.config.Secret), nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, s.keys.Keyfunc)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	claims, ok := token.Claims.(*Claims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
 	}
 
-	return nil, errors.New("invalid token")
+	// Client tokens carry the generation too; their API key is checked by
+	// APIKeyService.CheckClientToken
+	generation, err := s.revocation.Generation(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Generation < generation {
+		return nil, ErrTokenRevoked
+	}
+
+	// Single tokens are denied by jti, ended sessions by sid
+	for _, id := range []string{claims.ID, claims.SessionID} {
+		if id == "" {
+			continue
+		}
+		denied, err := s.revocation.IsDenied(id)
+		if err != nil {
+			return nil, err
+		}
+		if denied {
+			return nil, ErrTokenRevoked
+		}
+	}
+
+	return claims, nil
 }
 
-func (s *AuthService) ForgotPassword(email string) error {
+// JWKS returns the public keys that verify access tokens.
+func (s *AuthService) JWKS() []JWK {
+	return s.keys.JWKS()
+}
+
+func (s *AuthService) ForgotPassword(email string, client *models.ClientInfo) error {
+	// Count before the lookup, so unknown addresses are limited alike
+	if err := s.limitMailRequest("reset", email, client.IPAddress, s.authConfig.MailRequestEmailLimit, s.authConfig.MailRequestIPLimit); err != nil {
+		return err
+	}
+
 	user, err := s.userRepo.FindByEmail(email)
 	if err != nil {
 		// Don't reveal if user exists
@@ -150,50 +536,217 @@ func (s *AuthService) ForgotPassword(email string) error {
 		return err
 	}
 
-	// TODO: Send email with reset link
-	// For now, just log the token (in production, send email)
-	
-	return nil
+	s.audit(models.EventPasswordResetRequested, user.ID, client, nil)
+
+	// Send email with reset link
+	return s.sendEmail(user, "password_reset", map[string]string{
+		"Username": user.Username,
+		"Link":     s.appLink("/reset-password", token),
+	})
 }
 
-func (s *AuthService) ResetPassword(token, newPassword string) error {
-	// Find token
+func (s *AuthService) ResetPassword(token, newPassword string, client *models.ClientInfo) error {
+	// Check the new password against the account first, so a rejected
+	// password leaves the link usable for another try
 	userID, err := s.userRepo.FindPasswordResetToken(token)
+	if err == sql.ErrNoRows {
+		return ErrInvalidResetToken
+	}
 	if err != nil {
-		return errors.New("invalid or expired reset token")
+		return err
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.passwords.Validate("new_password", newPassword, user.Email, user.Username); err != nil {
+		return err
 	}
 
 	// Hash new password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	hashedPassword, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	// Redeem the token, so that it works only once even for concurrent
+	// requests
+	_, err = s.userRepo.ConsumePasswordResetToken(token)
+	if err == sql.ErrNoRows {
+		return ErrInvalidResetToken
+	}
 	if err != nil {
 		return err
 	}
 
 	// Update password
-	if err := s.userRepo.UpdatePassword(userID, string(hashedPassword)); err != nil {
+	if err := s.userRepo.UpdatePassword(userID, hashedPassword); err != nil {
 		return err
 	}
 
-	// Mark token as used
-	s.userRepo.MarkPasswordResetTokenUsed(token)
+	// Sign out everywhere and revoke the API keys
+	if err := s.LogoutAll(userID); err != nil {
+		return err
+	}
 
-	// Revoke all refresh tokens
-	s.userRepo.RevokeAllUserRefreshTokens(userID)
+	s.audit(models.EventPasswordReset, userID, client, nil)
 
-	return nil
+	// Resetting the password also lifts a login lock
+	return s.throttle.Reset(user.Email)
+}
+
+func (s *AuthService) SendVerificationEmail(user *models.User) error {
+	token, err := s.generateActionToken(user, purposeEmailVerification, s.authConfig.EmailVerificationExpiry)
+	if err != nil {
+		return err
+	}
+
+	return s.sendEmail(user, "email_verification", map[string]string{
+		"Username": user.Username,
+		"Link":     s.appLink("/verify-email", token),
+	})
 }
 
-func (s *AuthService) generateAccessToken(userID int64) (string, error) {
+func (s *AuthService) ResendVerification(email, ip string) error {
+	if err := s.limitMailRequest("verify", email, ip, s.authConfig.MailRequestEmailLimit, s.authConfig.MailRequestIPLimit); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil || user.EmailVerifiedAt.Valid {
+		// Don't reveal if user exists or is already verified
+		return nil
+	}
+
+	return s.SendVerificationEmail(user)
+}
+
+func (s *AuthService) VerifyEmail(token string) (*models.User, error) {
+	claims, err := s.parseActionToken(token, purposeEmailVerification)
+	if err != nil {
+		return nil, errors.New("invalid or expired verification token")
+	}
+
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err != nil || user.Email != claims.Email {
+		return nil, errors.New("invalid or expired verification token")
+	}
+
+	if err := s.userRepo.MarkEmailVerified(user.ID); err != nil {
+		return nil, err
+	}
+
+	return s.userRepo.FindByID(user.ID)
+}
+
+// IsEmailVerified reports whether the user has confirmed their address.
+func (s *AuthService) IsEmailVerified(userID int64) (bool, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerifiedAt.Valid, nil
+}
+
+// limitMailRequest counts a request for an email of the given kind
+// against the limits per address and per IP.
+func (s *AuthService) limitMailRequest(kind, email, ip string, emailLimit, ipLimit int) error {
+	if ip != "" {
+		if err := s.limiter.Allow(kind+":ip:"+ip, ipLimit); err != nil {
+			return err
+		}
+	}
+	return s.limiter.Allow(kind+":email:"+strings.ToLower(strings.TrimSpace(email)), emailLimit)
+}
+
+// sendEmail renders a template in the user's language and delivers it.
+func (s *AuthService) sendEmail(user *models.User, template string, data interface{}) error {
+	return s.sendEmailTo(user, user.Email, template, data)
+}
+
+// sendEmailTo is sendEmail for an address other than the user's current one.
+func (s *AuthService) sendEmailTo(user *models.User, to, template string, data interface{}) error {
+	lang, err := s.userRepo.FindLanguage(user.ID)
+	if err != nil {
+		return err
+	}
+
+	msg, err := mailer.Render(template, lang, data)
+	if err != nil {
+		return err
+	}
+	msg.To = to
+
+	return s.mailer.Send(msg)
+}
+
+// appLink builds a frontend URL carrying a token in its query string.
+func (s *AuthService) appLink(path, token string) string {
+	return s.mailConfig.AppURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func (s *AuthService) generateAccessToken(user *models.User, sessionID string) (string, error) {
+	jti, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+
 	claims := &Claims{
-		UserID: userID,
+		UserID:     user.ID,
+		Role:       user.Role,
+		SessionID:  sessionID,
+		Generation: user.TokenGeneration,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.config.AccessExpiry)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
 
+	return s.keys.Sign(claims)
+}
+
+func (s *AuthService) generateActionToken(user *models.User, purpose string, ttl time.Duration) (string, error) {
+	return s.signActionToken(&actionClaims{
+		UserID:  user.ID,
+		Email:   user.Email,
+		Purpose: purpose,
+	}, ttl)
+}
+
+func (s *AuthService) signActionToken(claims *actionClaims, ttl time.Duration) (string, error) {
+	claims.RegisteredClaims = jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(s.config.Secret))
+	return token.SignedString(s.actionKey(claims.Purpose))
+}
+
+func (s *AuthService) parseActionToken(tokenString, purpose string) (*actionClaims, error) {
+	claims := &actionClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return s.actionKey(purpose), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != purpose {
+		return nil, errors.New("invalid token purpose")
+	}
+
+	return claims, nil
+}
+
+// actionKey derives a per-purpose signing key so that emailed tokens can
+// never be accepted as access tokens or used for a different action.
+func (s *AuthService) actionKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(s.config.Secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
 }
 
 func (s *AuthService) generateRefreshToken() (string, error) {
@@ -203,4 +756,3 @@ func (s *AuthService) generateRefreshToken() (string, error) {
 	}
 	return hex.EncodeToString(bytes), nil
 }
-
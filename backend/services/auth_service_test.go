@@ -0,0 +1,106 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"sensory-navigator/config"
+	"sensory-navigator/models"
+)
+
+func newTestAuthService() *AuthService {
+	return &AuthService{
+		config: &config.JWTConfig{Secret: "test-secret-that-is-long-enough-for-hs256"},
+	}
+}
+
+func TestActionTokens(t *testing.T) {
+	s := newTestAuthService()
+	user := &models.User{ID: 42, Email: "user@example.com"}
+
+	tests := []struct {
+		name    string
+		purpose string
+		ttl     time.Duration
+		parseAs string
+		tamper  bool
+		wantErr bool
+	}{
+		{name: "valid", purpose: purposeEmailVerification, ttl: time.Hour, parseAs: purposeEmailVerification},
+		{name: "other purpose", purpose: purposeEmailVerification, ttl: time.Hour, parseAs: "password_reset", wantErr: true},
+		{name: "expired", purpose: purposeEmailVerification, ttl: -time.Minute, parseAs: purposeEmailVerification, wantErr: true},
+		{name: "tampered", purpose: purposeEmailVerification, ttl: time.Hour, parseAs: purposeEmailVerification, tamper: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := s.generateActionToken(user, tt.purpose, tt.ttl)
+			if err != nil {
+				t.Fatalf("generateActionToken() error = %v", err)
+			}
+			if tt.tamper {
+				token = token[:len(token)-2] + "xx"
+			}
+
+			claims, err := s.parseActionToken(token, tt.parseAs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseActionToken() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (claims.UserID != user.ID || claims.Email != user.Email) {
+				t.Errorf("claims = %+v, want user %d <%s>", claims, user.ID, user.Email)
+			}
+		})
+	}
+}
+
+func TestVerifyCredential(t *testing.T) {
+	s := newTestAuthService()
+	s.hasher = newTestHasher(t, testBcryptConfig)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct horse battery staple"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passwordUser := &models.User{ID: 1, Email: "alice@example.com", PasswordHash: string(hash)}
+	// Accounts created through OIDC have no password hash
+	oidcUser := &models.User{ID: 2, Email: "bob@example.com"}
+	otherUser := &models.User{ID: 3, Email: "carol@example.com"}
+
+	token := func(user *models.User, purpose string, ttl time.Duration) string {
+		t.Helper()
+		token, err := s.generateActionToken(user, purpose, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	movedUser := *oidcUser
+	movedUser.Email = "bob@old.example.com"
+
+	tests := []struct {
+		name   string
+		user   *models.User
+		secret string
+		want   bool
+	}{
+		{"password", passwordUser, "correct horse battery staple", true},
+		{"wrong password", passwordUser, "wrong", false},
+		{"password account ignores reauthentication token", passwordUser, token(passwordUser, purposeReauthentication, time.Minute), false},
+		{"oidc reauthentication token", oidcUser, token(oidcUser, purposeReauthentication, time.Minute), true},
+		{"oidc empty secret", oidcUser, "", false},
+		{"oidc guessed password", oidcUser, "correct horse battery staple", false},
+		{"oidc token of another user", oidcUser, token(otherUser, purposeReauthentication, time.Minute), false},
+		{"oidc token for another purpose", oidcUser, token(oidcUser, purposeEmailVerification, time.Minute), false},
+		{"oidc token sent to a previous address", oidcUser, token(&movedUser, purposeReauthentication, time.Minute), false},
+		{"oidc expired token", oidcUser, token(oidcUser, purposeReauthentication, -time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.verifyCredential(tt.user, tt.secret); got != tt.want {
+				t.Errorf("verifyCredential() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io"
+	"time"
+
+	"sensory-navigator/models"
+	"sensory-navigator/repository"
+)
+
+// exportPageSize is how many reviews or favorites are read per query.
+const exportPageSize = 500
+
+type ExportService struct {
+	userRepo     *repository.UserRepository
+	reviewRepo   *repository.ReviewRepository
+	favoriteRepo *repository.FavoriteRepository
+	prefRepo     *repository.SensoryPreferenceRepository
+}
+
+func NewExportService(userRepo *repository.UserRepository, reviewRepo *repository.ReviewRepository, favoriteRepo *repository.FavoriteRepository, prefRepo *repository.SensoryPreferenceRepository) *ExportService {
+	return &ExportService{
+		userRepo:     userRepo,
+		reviewRepo:   reviewRepo,
+		favoriteRepo: favoriteRepo,
+		prefRepo:     prefRepo,
+	}
+}
+
+// Export collects everything stored about the user.
+func (s *ExportService) Export(userID int64) (*models.UserDataExport, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &models.UserDataExport{
+		ExportedAt: time.Now().UTC().Format(time.RFC3339),
+		Profile:    user.ToResponse(),
+		Reviews:    []*models.ReviewResponse{},
+		Favorites:  []*models.FavoriteResponse{},
+		Sessions:   []models.SessionResponse{},
+	}
+
+	for offset := 0; ; offset += exportPageSize {
+		reviews, err := s.reviewRepo.FindByUserID(userID, exportPageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		export.Reviews = append(export.Reviews, reviews...)
+		if len(reviews) < exportPageSize {
+			break
+		}
+	}
+
+	for offset := 0; ; offset += exportPageSize {
+		favorites, err := s.favoriteRepo.FindByUserID(userID, exportPageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		export.Favorites = append(export.Favorites, favorites...)
+		if len(favorites) < exportPageSize {
+			break
+		}
+	}
+
+	if export.Settings, err = s.userRepo.FindSettings(userID); err != nil {
+		return nil, err
+	}
+
+	prefs, err := s.prefRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	export.SensoryProfile = models.NewUserSensoryProfile(prefs)
+
+	sessions, err := s.userRepo.FindSessions(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		export.Sessions = append(export.Sessions, session.ToResponse())
+	}
+
+	return export, nil
+}
+
+// WriteZip writes the export as a ZIP archive with one JSON file per
+// section.
+func (s *ExportService) WriteZip(w io.Writer, export *models.UserDataExport) error {
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"reviews.json", export.Reviews},
+		{"favorites.json", export.Favorites},
+		{"settings.json", export.Settings},
+		{"sensory_profile.json", export.SensoryProfile},
+		{"sessions.json", export.Sessions},
+	}
+
+	archive := zip.NewWriter(w)
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			return err
+		}
+	}
+
+	return archive.Close()
+}
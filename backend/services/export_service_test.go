@@ -0,0 +1,76 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"sensory-navigator/models"
+)
+
+func TestWriteZip(t *testing.T) {
+	export := &models.UserDataExport{
+		Profile:   models.UserResponse{ID: 7, Email: "user@example.com", Username: "user"},
+		Reviews:   []*models.ReviewResponse{},
+		Favorites: []*models.FavoriteResponse{},
+		Settings:  &models.UserSettings{Language: "en", Theme: "dark"},
+		SensoryProfile: models.NewUserSensoryProfile([]*models.SensoryPreference{
+			{Dimension: models.DimensionSoundLevel, Tolerance: 2, Weight: 3},
+		}),
+		Sessions: []models.SessionResponse{},
+	}
+
+	var buf bytes.Buffer
+	if err := (&ExportService{}).WriteZip(&buf, export); err != nil {
+		t.Fatalf("WriteZip() error = %v", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("archive is not a valid zip: %v", err)
+	}
+
+	contents := map[string]string{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("open %s: %v", f.Name, err)
+		}
+		var data json.RawMessage
+		if err := json.NewDecoder(r).Decode(&data); err != nil {
+			t.Fatalf("%s is not valid JSON: %v", f.Name, err)
+		}
+		r.Close()
+		compact := &bytes.Buffer{}
+		json.Compact(compact, data)
+		contents[f.Name] = compact.String()
+	}
+
+	tests := []struct {
+		file string
+		want string
+	}{
+		{file: "profile.json", want: `{"id":7,"email":"user@example.com","username":"user","email_verified":false,"role":"","created_at":""}`},
+		{file: "reviews.json", want: `[]`},
+		{file: "favorites.json", want: `[]`},
+		{file: "settings.json", want: `{"notifications_enabled":false,"email_notifications":false,"language":"en","theme":"dark"}`},
+		{file: "sensory_profile.json", want: `{"dimensions":{"sound_level":{"tolerance":2,"weight":3}}}`},
+		{file: "sessions.json", want: `[]`},
+	}
+
+	if len(contents) != len(tests) {
+		t.Errorf("archive has %d files, want %d", len(contents), len(tests))
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, ok := contents[tt.file]
+			if !ok {
+				t.Fatalf("%s missing from archive", tt.file)
+			}
+			if got != tt.want {
+				t.Errorf("%s = %s, want %s", tt.file, got, tt.want)
+			}
+		})
+	}
+}
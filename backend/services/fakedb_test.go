@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDB is a database/sql driver that answers statements with canned
+// results. A statement is answered by the first stub whose fragment it
+// contains; queries without a stub return no rows, execs affect none.
+type fakeDB struct {
+	mu         sync.Mutex
+	stubs      []fakeStub
+	statements []string
+}
+
+type fakeStub struct {
+	fragment string
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+	err      error
+}
+
+func newFakeDB() (*sql.DB, *fakeDB) {
+	f := &fakeDB{}
+	return sql.OpenDB(f), f
+}
+
+// onQuery answers queries containing fragment with rows.
+func (f *fakeDB) onQuery(fragment string, columns []string, rows ...[]driver.Value) {
+	f.stub(fakeStub{fragment: fragment, columns: columns, rows: rows})
+}
+
+// onExec answers statements containing fragment as affecting rows.
+func (f *fakeDB) onExec(fragment string, affected int64) {
+	f.stub(fakeStub{fragment: fragment, affected: affected})
+}
+
+// onError fails statements containing fragment with err.
+func (f *fakeDB) onError(fragment string, err error) {
+	f.stub(fakeStub{fragment: fragment, err: err})
+}
+
+func (f *fakeDB) stub(stub fakeStub) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stubs = append(f.stubs, stub)
+}
+
+// ran counts the statements run that contain fragment.
+func (f *fakeDB) ran(fragment string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	n := 0
+	for _, statement := range f.statements {
+		if strings.Contains(statement, fragment) {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeDB) answer(query string) fakeStub {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.statements = append(f.statements, query)
+	for _, stub := range f.stubs {
+		if strings.Contains(query, stub.fragment) {
+			return stub
+		}
+	}
+	return fakeStub{}
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error)                 { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	stub := s.db.answer(s.query)
+	if stub.err != nil {
+		return nil, stub.err
+	}
+	return driver.RowsAffected(stub.affected), nil
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	stub := s.db.answer(s.query)
+	if stub.err != nil {
+		return nil, stub.err
+	}
+	return &fakeRows{columns: stub.columns, rows: stub.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"sensory-navigator/config"
+)
+
+// KeySet holds the keys used to sign and verify access tokens.
+//
+// Keys are loaded from PEM files in JWTConfig.KeysDir; the file name without
+// extension is the key ID ("kid"). Private keys (PKCS#1 or PKCS#8, RSA or
+// Ed25519) can sign and verify, public-only keys (PKIX) are kept to verify
+// tokens signed before a rotation. Without a key directory the set falls
+// back to HS256 with JWTConfig.Secret.
+type KeySet struct {
+	keys   map[string]*signingKey
+	active *signingKey
+}
+
+type signingKey struct {
+	id      string
+	method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+// JWK is a public key in JSON Web Key format.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+func LoadKeySet(jwtConfig *config.JWTConfig) (*KeySet, error) {
+	if jwtConfig.KeysDir == "" {
+		key := &signingKey{
+			method:  jwt.SigningMethodHS256,
+			private: []byte(jwtConfig.Secret),
+			public:  []byte(jwtConfig.Secret),
+		}
+		return &KeySet{keys: map[string]*signingKey{"": key}, active: key}, nil
+	}
+
+	paths, err := filepath.Glob(filepath.Join(jwtConfig.KeysDir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	ks := &KeySet{keys: make(map[string]*signingKey)}
+	for _, path := range paths {
+		key, err := loadSigningKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load key %s: %w", path, err)
+		}
+		if _, exists := ks.keys[key.id]; exists {
+			return nil, fmt.Errorf("duplicate key ID %q", key.id)
+		}
+		ks.keys[key.id] = key
+
+		// Without an explicit active key the last private key by name signs
+		if key.private != nil && jwtConfig.ActiveKeyID == "" {
+			ks.active = key
+		}
+	}
+
+	if jwtConfig.ActiveKeyID != "" {
+		ks.active = ks.keys[jwtConfig.ActiveKeyID]
+	}
+	if ks.active == nil || ks.active.private == nil {
+		return nil, errors.New("no private signing key found in " + jwtConfig.KeysDir)
+	}
+
+	return ks, nil
+}
+
+func loadSigningKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	id := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(path), ".pem"), ".pub")
+	key := &signingKey{id: id}
+
+	var parsed interface{}
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.method, key.public = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.method, key.private, key.public = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.method, key.public = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	return key, nil
+}
+
+// Sign signs claims with the active key and tags the token with its kid.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	if ks.active.id != "" {
+		token.Header["kid"] = ks.active.id
+	}
+	return token.SignedString(ks.active.private)
+}
+
+// Keyfunc selects the verification key by kid and rejects tokens whose
+// algorithm does not match the key, preventing algorithm confusion.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+
+	return key.public, nil
+}
+
+// JWKS returns the public keys in JSON Web Key Set format. Symmetric keys
+// are never published.
+func (ks *KeySet) JWKS() []JWK {
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	jwks := []JWK{}
+	for _, id := range ids {
+		key := ks.keys[id]
+		jwk := JWK{KeyID: key.id, Use: "sig", Algorithm: key.method.Alg()}
+
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"sensory-navigator/config"
+	"sensory-navigator/models"
+)
+
+// LoginAttemptStore keeps failed login counters. RecordFailure counts
+// atomically and returns the counter including the new failure; it starts
+// a new count when the previous failure is older than window. Release takes
+// one failure back.
+type LoginAttemptStore interface {
+	Get(key string) (*models.LoginAttempt, error)
+	RecordFailure(key string, window time.Duration) (*models.LoginAttempt, error)
+	Release(key string) error
+	Reset(key string) error
+}
+
+// ThrottleError is returned by Login while an email address or IP has to
+// wait before trying again. Locked distinguishes a temporary lock from a
+// progressive delay.
+type ThrottleError struct {
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	if e.Locked {
+		return "account temporarily locked due to too many failed login attempts; reset your password to unlock it"
+	}
+	return "too many failed login attempts, try again later"
+}
+
+// LoginThrottle slows down repeated failed logins per email and per IP:
+// after DelayAfter failures every attempt has to wait an exponentially
+// growing delay, after LockAfter failures the key is locked for
+// LockDuration.
+type LoginThrottle struct {
+	store  LoginAttemptStore
+	config *config.LoginThrottleConfig
+}
+
+func NewLoginThrottle(store LoginAttemptStore, throttleConfig *config.LoginThrottleConfig) *LoginThrottle {
+	return &LoginThrottle{
+		store:  store,
+		config: throttleConfig,
+	}
+}
+
+// Attempt returns a *ThrottleError if any of the keys for this login is
+// currently delayed or locked. Otherwise it counts the attempt as failed
+// before the credentials are checked, so that concurrent guesses see each
+// other; call RecordSuccess if they turn out right.
+func (t *LoginThrottle) Attempt(email, ip string) error {
+	keys := t.keys(email, ip)
+	seen := make([]*models.LoginAttempt, len(keys))
+	for i, key := range keys {
+		attempt, err := t.store.Get(key.name)
+		if err != nil {
+			return err
+		}
+		// Refused attempts are not counted, so waiting is enough to retry
+		if err := t.evaluate(attempt, key.multiplier); err != nil {
+			return err
+		}
+		seen[i] = attempt
+	}
+
+	for i, key := range keys {
+		counted, err := t.store.RecordFailure(key.name, t.config.Window)
+		if err != nil {
+			return err
+		}
+		// Attempts counted since Get went first, this one follows the last
+		// of them right away
+		if counted.Failures > seen[i].Failures+1 {
+			previous := &models.LoginAttempt{Failures: counted.Failures - 1, LastFailureAt: counted.LastFailureAt}
+			if err := t.evaluate(previous, key.multiplier); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RecordSuccess takes back the failure Attempt counted for the email and
+// the IP once the credentials were right.
+func (t *LoginThrottle) RecordSuccess(email, ip string) error {
+	for _, key := range t.keys(email, ip) {
+		if err := t.store.Release(key.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset clears the counter for an email address after a successful login
+// or a password reset. IP counters are left to expire so that one valid
+// account cannot be used to clear an attacker's address.
+func (t *LoginThrottle) Reset(email string) error {
+	return t.store.Reset(emailKey(email))
+}
+
+type throttleKey struct {
+	name string
+	// multiplier scales the thresholds, IPs may be shared behind NAT
+	multiplier int
+}
+
+func (t *LoginThrottle) keys(email, ip string) []throttleKey {
+	keys := []throttleKey{{name: emailKey(email), multiplier: 1}}
+	if ip != "" {
+		keys = append(keys, throttleKey{name: "ip:" + ip, multiplier: t.config.IPMultiplier})
+	}
+	return keys
+}
+
+// evaluate returns a *ThrottleError if the next attempt after the counted
+// failures has to wait. A lock lasts LockDuration from the last failure
+// even when that is longer than Window.
+func (t *LoginThrottle) evaluate(attempt *models.LoginAttempt, multiplier int) error {
+	if attempt.Failures == 0 {
+		return nil
+	}
+
+	lockAfter := t.config.LockAfter * multiplier
+	delayAfter := t.config.DelayAfter * multiplier
+
+	if attempt.Failures >= lockAfter {
+		if wait := time.Until(attempt.LastFailureAt.Add(t.config.LockDuration)); wait > 0 {
+			return &ThrottleError{Locked: true, RetryAfter: wait}
+		}
+		return nil
+	}
+
+	if time.Since(attempt.LastFailureAt) > t.config.Window {
+		return nil
+	}
+
+	if attempt.Failures >= delayAfter {
+		delay := t.config.BaseDelay << uint(attempt.Failures-delayAfter)
+		if delay > t.config.MaxDelay || delay <= 0 {
+			delay = t.config.MaxDelay
+		}
+		if wait := time.Until(attempt.LastFailureAt.Add(delay)); wait > 0 {
+			return &ThrottleError{RetryAfter: wait}
+		}
+	}
+
+	return nil
+}
+
+func emailKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// MemoryLoginAttemptStore keeps counters in process memory. It suits a
+// single instance; use the Postgres store when running several.
+type MemoryLoginAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]*models.LoginAttempt
+	// retention keeps counters around for at least this long, so that
+	// pruning does not end locks longer than the counting window
+	retention time.Duration
+}
+
+// maxMemoryAttempts bounds the map before stale entries are pruned.
+const maxMemoryAttempts = 10000
+
+func NewMemoryLoginAttemptStore(retention time.Duration) *MemoryLoginAttemptStore {
+	return &MemoryLoginAttemptStore{
+		attempts:  make(map[string]*models.LoginAttempt),
+		retention: retention,
+	}
+}
+
+func (m *MemoryLoginAttemptStore) Get(key string) (*models.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if attempt, ok := m.attempts[key]; ok {
+		copied := *attempt
+		return &copied, nil
+	}
+	return &models.LoginAttempt{Key: key}, nil
+}
+
+func (m *MemoryLoginAttemptStore) RecordFailure(key string, window time.Duration) (*models.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	attempt, ok := m.attempts[key]
+	if !ok || now.Sub(attempt.LastFailureAt) > window {
+		if len(m.attempts) >= maxMemoryAttempts {
+			m.prune(now, window)
+		}
+		attempt = &models.LoginAttempt{Key: key}
+		m.attempts[key] = attempt
+	}
+	attempt.Failures++
+	attempt.LastFailureAt = now
+
+	copied := *attempt
+	return &copied, nil
+}
+
+func (m *MemoryLoginAttemptStore) Release(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if attempt, ok := m.attempts[key]; ok && attempt.Failures > 0 {
+		attempt.Failures--
+	}
+	return nil
+}
+
+func (m *MemoryLoginAttemptStore) Reset(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, key)
+	return nil
+}
+
+func (m *MemoryLoginAttemptStore) prune(now time.Time, window time.Duration) {
+	if m.retention > window {
+		window = m.retention
+	}
+	for key, attempt := range m.attempts {
+		if now.Sub(attempt.LastFailureAt) > window {
+			delete(m.attempts, key)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"sensory-navigator/config"
+	"sensory-navigator/models"
+)
+
+func newTestLoginThrottle() *LoginThrottle {
+	return NewLoginThrottle(NewMemoryLoginAttemptStore(time.Hour), &config.LoginThrottleConfig{
+		DelayAfter: 5, LockAfter: 10, Window: time.Hour, IPMultiplier: 5,
+	})
+}
+
+func TestLoginThrottleEvaluate(t *testing.T) {
+	defaults := config.LoginThrottleConfig{
+		DelayAfter:   3,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockAfter:    10,
+		LockDuration: 15 * time.Minute,
+		Window:       time.Hour,
+		IPMultiplier: 5,
+	}
+	longLock := defaults
+	longLock.LockDuration = 2 * time.Hour
+	noLock := defaults
+	noLock.LockAfter = 1000
+
+	tests := []struct {
+		name       string
+		config     config.LoginThrottleConfig
+		failures   int
+		ago        time.Duration
+		multiplier int
+		// wantWait is zero when the attempt may go ahead
+		wantWait   time.Duration
+		wantLocked bool
+	}{
+		{"no failures", defaults, 0, 0, 1, 0, false},
+		{"below the delay threshold", defaults, 2, 0, 1, 0, false},
+		{"first delay", defaults, 3, 0, 1, time.Second, false},
+		{"first delay elapsed", defaults, 3, 2 * time.Second, 1, 0, false},
+		{"delay doubles", defaults, 4, time.Second, 1, time.Second, false},
+		{"delay capped", defaults, 9, 0, 1, time.Minute, false},
+		{"delay shift overflow capped", noLock, 3 + 70, 0, 1, time.Minute, false},
+		{"delay forgotten after the window", defaults, 9, 2 * time.Hour, 1, 0, false},
+		{"lock", defaults, 10, 0, 1, 15 * time.Minute, true},
+		{"lock almost over", defaults, 10, 14 * time.Minute, 1, time.Minute, true},
+		{"lock over", defaults, 10, 16 * time.Minute, 1, 0, false},
+		{"far past the lock threshold", defaults, 50, 0, 1, 15 * time.Minute, true},
+		{"lock outlasts the window", longLock, 10, 90 * time.Minute, 1, 30 * time.Minute, true},
+		{"lock over after the window", longLock, 10, 3 * time.Hour, 1, 0, false},
+		{"ip below its delay threshold", defaults, 14, 0, 5, 0, false},
+		{"ip first delay", defaults, 15, 0, 5, time.Second, false},
+		{"ip below its lock threshold", defaults, 49, 0, 5, time.Minute, false},
+		{"ip lock", defaults, 50, 0, 5, 15 * time.Minute, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := NewLoginThrottle(nil, &tt.config)
+			attempt := &models.LoginAttempt{Failures: tt.failures, LastFailureAt: time.Now().Add(-tt.ago)}
+
+			err := throttle.evaluate(attempt, tt.multiplier)
+			if tt.wantWait == 0 {
+				if err != nil {
+					t.Fatalf("evaluate() = %v, want nil", err)
+				}
+				return
+			}
+
+			throttleErr, ok := err.(*ThrottleError)
+			if !ok {
+				t.Fatalf("evaluate() = %v, want a *ThrottleError", err)
+			}
+			if throttleErr.Locked != tt.wantLocked {
+				t.Errorf("Locked = %v, want %v", throttleErr.Locked, tt.wantLocked)
+			}
+			// Some time passes between building the attempt and evaluating it
+			if d := tt.wantWait - throttleErr.RetryAfter; d < 0 || d > 100*time.Millisecond {
+				t.Errorf("RetryAfter = %v, want %v", throttleErr.RetryAfter, tt.wantWait)
+			}
+		})
+	}
+}
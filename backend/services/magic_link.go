@@ -0,0 +1,76 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"sensory-navigator/models"
+)
+
+var ErrInvalidMagicLink = errors.New("invalid or expired login link")
+
+// RequestMagicLink emails a single-use login link. Like ForgotPassword it
+// does not reveal whether the address has an account. With bindDevice the
+// returned device token has to accompany the link, so it only works on the
+// requesting client; it is returned for unknown addresses as well.
+func (s *AuthService) RequestMagicLink(email string, bindDevice bool, client *models.ClientInfo) (string, error) {
+	// Count before the lookup, so unknown addresses are limited alike
+	if err := s.limitMailRequest("magic", email, client.IPAddress, s.authConfig.MagicLinkEmailLimit, s.authConfig.MagicLinkIPLimit); err != nil {
+		return "", err
+	}
+
+	var deviceToken string
+	if bindDevice {
+		var err error
+		if deviceToken, err = s.generateRefreshToken(); err != nil {
+			return "", err
+		}
+	}
+
+	user, err := s.userRepo.FindByEmail(email)
+	if err != nil || user.DeletionScheduledAt.Valid {
+		return deviceToken, nil
+	}
+
+	s.audit(models.EventMagicLinkRequested, user.ID, client, nil)
+
+	token, err := s.generateRefreshToken()
+	if err != nil {
+		return deviceToken, err
+	}
+
+	expiresAt := time.Now().Add(s.authConfig.MagicLinkExpiry)
+	if err := s.userRepo.CreateMagicLinkToken(user.ID, token, deviceToken, client.IPAddress, expiresAt); err != nil {
+		return deviceToken, err
+	}
+
+	return deviceToken, s.sendEmail(user, "magic_link", map[string]interface{}{
+		"Username": user.Username,
+		"Link":     s.appLink("/magic-link", token),
+		"Minutes":  int(s.authConfig.MagicLinkExpiry.Minutes()),
+	})
+}
+
+// VerifyMagicLink redeems a login link. Opening it proves control of the
+// address, so the email counts as verified. Two-factor authentication
+// still applies.
+func (s *AuthService) VerifyMagicLink(token, deviceToken string, client *models.ClientInfo) (*LoginResult, error) {
+	userID, err := s.userRepo.ConsumeMagicLinkToken(token, deviceToken)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidMagicLink
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.MarkEmailVerified(userID); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.LoginAuthenticatedUser(user, client)
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"sensory-navigator/config"
+	"sensory-navigator/mailer"
+	"sensory-navigator/models"
+	"sensory-navigator/repository"
+)
+
+// recordingMailer keeps the messages it is asked to send.
+type recordingMailer struct {
+	sent []*mailer.Message
+}
+
+func (m *recordingMailer) Send(msg *mailer.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func newMagicLinkTestService(db *repository.UserRepository, m mailer.Mailer) *AuthService {
+	return &AuthService{
+		userRepo: db,
+		auditor:  &recordingAuditor{},
+		limiter:  NewRateLimiter(time.Hour),
+		authConfig: &config.AuthConfig{
+			MagicLinkExpiry:     15 * time.Minute,
+			MagicLinkEmailLimit: 2,
+			MagicLinkIPLimit:    10,
+		},
+		mailer:     m,
+		mailConfig: &config.MailConfig{AppURL: "https://app.example.com"},
+	}
+}
+
+func TestRequestMagicLink(t *testing.T) {
+	tests := []struct {
+		name        string
+		user        []driver.Value
+		bindDevice  bool
+		requests    int
+		wantErr     bool
+		wantDevice  bool
+		wantStored  int
+		wantSentTo  string
+		wantLimited bool
+	}{
+		{name: "known address", user: magicLinkUserRow(false), requests: 1, wantStored: 1, wantSentTo: "user@example.com"},
+		{name: "bound to the device", user: magicLinkUserRow(false), bindDevice: true, requests: 1, wantDevice: true, wantStored: 1, wantSentTo: "user@example.com"},
+		{name: "unknown address looks the same", bindDevice: true, requests: 1, wantDevice: true},
+		{name: "account pending deletion", user: magicLinkUserRow(true), requests: 1},
+		{name: "over the email limit", user: magicLinkUserRow(false), requests: 3, wantErr: true, wantStored: 2, wantSentTo: "user@example.com", wantLimited: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB()
+			if tt.user != nil {
+				fake.onQuery("FROM users WHERE email = $1", userColumns, tt.user)
+			}
+			m := &recordingMailer{}
+			s := newMagicLinkTestService(repository.NewUserRepository(db), m)
+
+			var deviceToken string
+			var err error
+			for i := 0; i < tt.requests; i++ {
+				deviceToken, err = s.RequestMagicLink("User@Example.com ", tt.bindDevice, &models.ClientInfo{IPAddress: "203.0.113.1"})
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("RequestMagicLink() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var limitErr *RateLimitError
+			if limited := errors.As(err, &limitErr); limited != tt.wantLimited {
+				t.Errorf("rate limited = %v, want %v", limited, tt.wantLimited)
+			}
+			if (deviceToken != "") != tt.wantDevice {
+				t.Errorf("device token = %q, want one: %v", deviceToken, tt.wantDevice)
+			}
+			if stored := fake.ran("INSERT INTO magic_link_tokens"); stored != tt.wantStored {
+				t.Errorf("tokens stored = %d, want %d", stored, tt.wantStored)
+			}
+			if len(m.sent) != tt.wantStored {
+				t.Fatalf("emails sent = %d, want %d", len(m.sent), tt.wantStored)
+			}
+			for _, msg := range m.sent {
+				if msg.To != tt.wantSentTo || !strings.Contains(msg.Text, "https://app.example.com/magic-link?token=") {
+					t.Errorf("email to %q does not carry the login link:\n%s", msg.To, msg.Text)
+				}
+			}
+		})
+	}
+}
+
+func magicLinkUserRow(pendingDeletion bool) []driver.Value {
+	now := time.Now()
+	var deletionAt driver.Value
+	if pendingDeletion {
+		deletionAt = now.Add(24 * time.Hour)
+	}
+	return []driver.Value{int64(42), "user@example.com", "", "user", nil, nil, nil, models.RoleUser, deletionAt, int64(0), now, now}
+}
+
+func TestVerifyMagicLinkRejectsUnknownToken(t *testing.T) {
+	db, fake := newFakeDB()
+	s := newMagicLinkTestService(repository.NewUserRepository(db), &recordingMailer{})
+
+	if _, err := s.VerifyMagicLink("token", "", &models.ClientInfo{}); err != ErrInvalidMagicLink {
+		t.Fatalf("VerifyMagicLink() error = %v, want %v", err, ErrInvalidMagicLink)
+	}
+	if fake.ran("UPDATE users SET email_verified_at") > 0 {
+		t.Error("email marked verified for an unknown link")
+	}
+}
@@ -0,0 +1,445 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"sensory-navigator/config"
+	"sensory-navigator/models"
+	"sensory-navigator/repository"
+)
+
+var (
+	ErrUnknownOIDCProvider = errors.New("unknown identity provider")
+	ErrInvalidOIDCState    = errors.New("invalid or expired login state")
+	ErrOIDCEmailRequired   = errors.New("the identity provider did not share an email address")
+	ErrOIDCAccountExists   = errors.New("an account with this email already exists; sign in with your password and verify your email to link it")
+	ErrOIDCLoginConflict   = errors.New("the account is being set up by another login; try again")
+)
+
+// jwksRefreshInterval limits how often an unknown kid triggers a JWKS fetch.
+const jwksRefreshInterval = time.Minute
+
+// resolveUserAttempts bounds the retries when concurrent logins create the
+// same account or identity.
+const resolveUserAttempts = 2
+
+// OIDCService implements the relying-party side of the OpenID Connect
+// authorization code flow with PKCE.
+type OIDCService struct {
+	config       *config.OIDCConfig
+	userRepo     *repository.UserRepository
+	identityRepo *repository.IdentityRepository
+	authService  *AuthService
+	httpClient   *http.Client
+
+	mu        sync.Mutex
+	providers map[string]*oidcProvider
+}
+
+// oidcProvider caches a provider's discovery document and signing keys.
+type oidcProvider struct {
+	name   string
+	config config.OIDCProviderConfig
+
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+type idTokenClaims struct {
+	Nonce             string      `json:"nonce"`
+	Email             string      `json:"email"`
+	EmailVerified     interface{} `json:"email_verified"`
+	Name              string      `json:"name"`
+	PreferredUsername string      `json:"preferred_username"`
+	jwt.RegisteredClaims
+}
+
+func NewOIDCService(oidcConfig *config.OIDCConfig, userRepo *repository.UserRepository, identityRepo *repository.IdentityRepository, authService *AuthService) *OIDCService {
+	return &OIDCService{
+		config:       oidcConfig,
+		userRepo:     userRepo,
+		identityRepo: identityRepo,
+		authService:  authService,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		providers:    make(map[string]*oidcProvider),
+	}
+}
+
+// StartURL creates a pending authorization request and returns the URL the
+// browser should be redirected to, and the state, which the caller has to
+// keep in the browser and hand back to Callback.
+func (s *OIDCService) StartURL(providerName string) (string, string, error) {
+	provider, err := s.provider(providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomURLToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomURLToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomURLToken(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	pending := &models.OIDCState{Provider: providerName, CodeVerifier: verifier, Nonce: nonce}
+	if err := s.identityRepo.SaveState(state, pending, time.Now().Add(s.config.StateExpiry)); err != nil {
+		return "", "", err
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", provider.config.ClientID)
+	query.Set("redirect_uri", s.redirectURI(providerName))
+	query.Set("scope", strings.Join(provider.config.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+
+	separator := "?"
+	if strings.Contains(provider.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return provider.AuthorizationEndpoint + separator + query.Encode(), state, nil
+}
+
+// Callback redeems the authorization code, verifies the ID token and logs
+// in the linked user, linking or creating an account on first use.
+// browserState is the state kept by the browser that started the login;
+// without it anyone could send a victim the callback URL of their own
+// login and sign the victim into the attacker's account.
+func (s *OIDCService) Callback(providerName, code, state, browserState string, client *models.ClientInfo) (*LoginResult, error) {
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return nil, ErrInvalidOIDCState
+	}
+
+	pending, err := s.identityRepo.ConsumeState(state)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidOIDCState
+	}
+	if err != nil {
+		return nil, err
+	}
+	if pending.Provider != providerName {
+		return nil, ErrInvalidOIDCState
+	}
+
+	provider, err := s.provider(providerName)
+	if err != nil {
+		return nil, err
+	}
+
+	rawIDToken, err := s.exchangeCode(provider, code, pending.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.verifyIDToken(provider, rawIDToken, pending.Nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *models.User
+	for attempt := 0; attempt < resolveUserAttempts; attempt++ {
+		// A concurrent login may create the account or identity first,
+		// which the next attempt finds
+		user, err = s.resolveUser(providerName, claims)
+		if !repository.IsUniqueViolation(err) {
+			break
+		}
+	}
+	if repository.IsUniqueViolation(err) {
+		return nil, ErrOIDCLoginConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.authService.LoginAuthenticatedUser(user, client)
+}
+
+func (s *OIDCService) resolveUser(providerName string, claims *idTokenClaims) (*models.User, error) {
+	identity, err := s.identityRepo.FindByProviderSubject(providerName, claims.Subject)
+	if err == nil {
+		if err := s.identityRepo.TouchLastLogin(identity.ID); err != nil {
+			return nil, err
+		}
+		return s.userRepo.FindByID(identity.UserID)
+	}
+	if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if claims.Email == "" {
+		return nil, ErrOIDCEmailRequired
+	}
+	emailVerified := isTrue(claims.EmailVerified)
+
+	user, err := s.userRepo.FindByEmail(claims.Email)
+	switch {
+	case err == nil:
+		// Link only when both sides proved ownership of the address,
+		// otherwise whoever registered it first could hijack the other
+		if !emailVerified || !user.EmailVerifiedAt.Valid {
+			return nil, ErrOIDCAccountExists
+		}
+	case err == sql.ErrNoRows:
+		user, err = s.userRepo.CreateExternal(claims.Email, usernameFromClaims(claims), emailVerified)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	if err := s.identityRepo.Create(user.ID, providerName, claims.Subject, claims.Email); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// StateExpiry is how long a started login can be completed.
+func (s *OIDCService) StateExpiry() time.Duration {
+	return s.config.StateExpiry
+}
+
+// SecureCallback reports whether callbacks arrive over HTTPS.
+func (s *OIDCService) SecureCallback() bool {
+	return strings.HasPrefix(s.config.RedirectBaseURL, "https://")
+}
+
+func (s *OIDCService) redirectURI(providerName string) string {
+	return strings.TrimRight(s.config.RedirectBaseURL, "/") + "/api/auth/oidc/" + providerName + "/callback"
+}
+
+// provider returns the provider with its discovery document loaded.
+func (s *OIDCService) provider(name string) (*oidcProvider, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if provider, ok := s.providers[name]; ok {
+		return provider, nil
+	}
+
+	providerConfig, ok := s.config.Providers[name]
+	if !ok {
+		return nil, ErrUnknownOIDCProvider
+	}
+
+	provider := &oidcProvider{name: name, config: providerConfig}
+	discoveryURL := strings.TrimRight(providerConfig.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := s.getJSON(discoveryURL, provider); err != nil {
+		return nil, fmt.Errorf("failed to discover %s: %w", name, err)
+	}
+	if strings.TrimRight(provider.Issuer, "/") != strings.TrimRight(providerConfig.Issuer, "/") {
+		return nil, fmt.Errorf("issuer mismatch for %s: %s", name, provider.Issuer)
+	}
+
+	s.providers[name] = provider
+	return provider, nil
+}
+
+func (s *OIDCService) exchangeCode(provider *oidcProvider, code, verifier string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", s.redirectURI(provider.name))
+	form.Set("code_verifier", verifier)
+	form.Set("client_id", provider.config.ClientID)
+	if provider.config.ClientSecret != "" {
+		form.Set("client_secret", provider.config.ClientSecret)
+	}
+
+	resp, err := s.httpClient.PostForm(provider.TokenEndpoint, form)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("invalid token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token exchange failed: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+
+	return body.IDToken, nil
+}
+
+func (s *OIDCService) verifyIDToken(provider *oidcProvider, rawIDToken, nonce string) (*idTokenClaims, error) {
+	claims := &idTokenClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return s.providerKey(provider, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithIssuer(provider.Issuer),
+		jwt.WithAudience(provider.config.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid id_token: %w", err)
+	}
+
+	if claims.Nonce != nonce {
+		return nil, errors.New("invalid id_token: nonce mismatch")
+	}
+	if claims.Subject == "" {
+		return nil, errors.New("invalid id_token: missing subject")
+	}
+
+	return claims, nil
+}
+
+// providerKey returns the provider's signing key, refetching the JWKS when
+// the key ID is unknown (the provider may have rotated keys).
+func (s *OIDCService) providerKey(provider *oidcProvider, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if key, ok := provider.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(provider.keysFetchedAt) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown key ID %q", kid)
+	}
+
+	var jwks struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := s.getJSON(provider.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+
+	provider.keys = make(map[string]crypto.PublicKey)
+	provider.keysFetchedAt = time.Now()
+	for _, jwk := range jwks.Keys {
+		if key, err := jwk.publicKey(); err == nil {
+			provider.keys[jwk.KeyID] = key
+		}
+	}
+
+	if key, ok := provider.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key ID %q", kid)
+}
+
+func (s *OIDCService) getJSON(url string, target interface{}) error {
+	resp, err := s.httpClient.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// publicKey converts an RSA or P-256 JWK into a public key.
+func (j JWK) publicKey() (crypto.PublicKey, error) {
+	switch j.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if j.Curve != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", j.Curve)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", j.KeyType)
+	}
+}
+
+// isTrue accepts email_verified as a boolean or, as some providers send
+// it, as a string.
+func isTrue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
+
+func usernameFromClaims(claims *idTokenClaims) string {
+	for _, name := range []string{claims.Name, claims.PreferredUsername} {
+		if len([]rune(strings.TrimSpace(name))) >= 2 {
+			return strings.TrimSpace(name)
+		}
+	}
+
+	local := strings.SplitN(claims.Email, "@", 2)[0]
+	if len([]rune(local)) >= 2 {
+		return local
+	}
+	return "user"
+}
+
+func randomURLToken(size int) (string, error) {
+	bytes := make([]byte, size)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
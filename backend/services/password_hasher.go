@@ -0,0 +1,170 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"sensory-navigator/config"
+)
+
+const (
+	HashArgon2id = "argon2id"
+	HashBcrypt   = "bcrypt"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+// passwordAlgorithm is one way of hashing passwords. Hashes are stored in
+// the modular crypt / PHC string format, which names the algorithm and its
+// parameters, so hashes of every supported algorithm can be verified.
+type passwordAlgorithm interface {
+	Hash(password string) (string, error)
+	// Owns reports whether hash was made by this algorithm
+	Owns(hash string) bool
+	// Verify reports whether password matches hash, and whether hash was
+	// made with parameters other than the configured ones
+	Verify(hash, password string) (match, outdated bool)
+}
+
+// PasswordHasher makes new hashes with the configured algorithm and
+// verifies hashes of any supported one, flagging those that should be
+// replaced.
+type PasswordHasher struct {
+	current    passwordAlgorithm
+	algorithms []passwordAlgorithm
+}
+
+func NewPasswordHasher(hashConfig *config.PasswordHashConfig) (*PasswordHasher, error) {
+	bcryptAlgorithm := &bcryptHasher{cost: hashConfig.BcryptCost}
+	argon2Algorithm := &argon2idHasher{
+		memory:  hashConfig.Argon2Memory,
+		time:    hashConfig.Argon2Time,
+		threads: hashConfig.Argon2Threads,
+	}
+
+	hasher := &PasswordHasher{algorithms: []passwordAlgorithm{argon2Algorithm, bcryptAlgorithm}}
+	switch hashConfig.Algorithm {
+	case HashArgon2id:
+		if argon2Algorithm.memory == 0 || argon2Algorithm.time == 0 || argon2Algorithm.threads == 0 {
+			return nil, fmt.Errorf("invalid argon2id parameters m=%d,t=%d,p=%d", argon2Algorithm.memory, argon2Algorithm.time, argon2Algorithm.threads)
+		}
+		hasher.current = argon2Algorithm
+	case HashBcrypt:
+		if bcryptAlgorithm.cost < bcrypt.MinCost || bcryptAlgorithm.cost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost %d", bcryptAlgorithm.cost)
+		}
+		hasher.current = bcryptAlgorithm
+	default:
+		return nil, fmt.Errorf("unknown password hash algorithm %q", hashConfig.Algorithm)
+	}
+
+	return hasher, nil
+}
+
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	return h.current.Hash(password)
+}
+
+// Verify reports whether password matches hash, and whether hash should be
+// replaced by one made with Hash. Unknown or malformed hashes never match.
+func (h *PasswordHasher) Verify(hash, password string) (match, rehash bool) {
+	for _, algorithm := range h.algorithms {
+		if !algorithm.Owns(hash) {
+			continue
+		}
+		match, outdated := algorithm.Verify(hash, password)
+		return match, match && (outdated || algorithm != h.current)
+	}
+	return false, false
+}
+
+type bcryptHasher struct {
+	cost int
+}
+
+func (b *bcryptHasher) Hash(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), b.cost)
+	return string(hash), err
+}
+
+func (b *bcryptHasher) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (b *bcryptHasher) Verify(hash, password string) (bool, bool) {
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, err != nil || cost != b.cost
+}
+
+// argon2idHasher encodes hashes as
+// $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>
+// with unpadded base64 salt and key.
+type argon2idHasher struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+}
+
+func (a *argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, a.time, a.memory, a.threads, argon2KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, a.memory, a.time, a.threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (a *argon2idHasher) Owns(hash string) bool {
+	return strings.HasPrefix(hash, "$argon2id$")
+}
+
+func (a *argon2idHasher) Verify(hash, password string) (bool, bool) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false, false
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return false, false
+	}
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false, false
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return false, false
+	}
+
+	outdated := memory != a.memory || time != a.time || threads != a.threads ||
+		len(salt) != argon2SaltLength || len(key) != argon2KeyLength
+	return true, outdated
+}
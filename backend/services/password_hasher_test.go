@@ -0,0 +1,149 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"sensory-navigator/config"
+)
+
+// Cheap parameters keep the tests fast; the format does not depend on them.
+var (
+	testArgon2Config = &config.PasswordHashConfig{
+		Algorithm:     HashArgon2id,
+		BcryptCost:    bcrypt.MinCost,
+		Argon2Memory:  64,
+		Argon2Time:    1,
+		Argon2Threads: 1,
+	}
+	testBcryptConfig = &config.PasswordHashConfig{
+		Algorithm:     HashBcrypt,
+		BcryptCost:    bcrypt.MinCost,
+		Argon2Memory:  64,
+		Argon2Time:    1,
+		Argon2Threads: 1,
+	}
+)
+
+func newTestHasher(t *testing.T, hashConfig *config.PasswordHashConfig) *PasswordHasher {
+	t.Helper()
+	hasher, err := NewPasswordHasher(hashConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hasher
+}
+
+func mustHash(t *testing.T, hasher *PasswordHasher, password string) string {
+	t.Helper()
+	hash, err := hasher.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash
+}
+
+func bcryptHash(t *testing.T, password string, cost int) string {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), cost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(hash)
+}
+
+// argon2idPHC builds a hash the way another implementation would.
+func argon2idPHC(password string, salt []byte, version int, memory, time uint32, threads uint8, keyLength uint32) string {
+	key := argon2.IDKey([]byte(password), salt, time, memory, threads, keyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		version, memory, time, threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	)
+}
+
+func TestArgon2idHashFormat(t *testing.T) {
+	hasher := newTestHasher(t, testArgon2Config)
+	hash := mustHash(t, hasher, "secret")
+
+	// 16 byte salt and 32 byte key in unpadded base64
+	format := regexp.MustCompile(`^\$argon2id\$v=19\$m=64,t=1,p=1\$[A-Za-z0-9+/]{22}\$[A-Za-z0-9+/]{43}$`)
+	if !format.MatchString(hash) {
+		t.Errorf("Hash() = %q, not in PHC format", hash)
+	}
+	if other := mustHash(t, hasher, "secret"); other == hash {
+		t.Error("Hash() returned the same hash twice, the salt is not random")
+	}
+}
+
+func TestPasswordHasherVerify(t *testing.T) {
+	argon2Hasher := newTestHasher(t, testArgon2Config)
+	bcryptHasher := newTestHasher(t, testBcryptConfig)
+	salt := []byte("0123456789abcdef")
+
+	tests := []struct {
+		name       string
+		hasher     *PasswordHasher
+		hash       string
+		password   string
+		wantMatch  bool
+		wantRehash bool
+	}{
+		{"argon2id current", argon2Hasher, mustHash(t, argon2Hasher, "secret"), "secret", true, false},
+		{"argon2id wrong password", argon2Hasher, mustHash(t, argon2Hasher, "secret"), "Secret", false, false},
+		{"argon2id from another implementation", argon2Hasher, argon2idPHC("secret", salt, argon2.Version, 64, 1, 1, 32), "secret", true, false},
+		{"argon2id older memory", argon2Hasher, argon2idPHC("secret", salt, argon2.Version, 32, 1, 1, 32), "secret", true, true},
+		{"argon2id older time", argon2Hasher, argon2idPHC("secret", salt, argon2.Version, 64, 2, 1, 32), "secret", true, true},
+		{"argon2id shorter key", argon2Hasher, argon2idPHC("secret", salt, argon2.Version, 64, 1, 1, 16), "secret", true, true},
+		{"argon2id shorter salt", argon2Hasher, argon2idPHC("secret", salt[:8], argon2.Version, 64, 1, 1, 32), "secret", true, true},
+		{"argon2id outdated but wrong password", argon2Hasher, argon2idPHC("secret", salt, argon2.Version, 32, 1, 1, 32), "other", false, false},
+		{"argon2id unsupported version", argon2Hasher, argon2idPHC("secret", salt, 0x10, 64, 1, 1, 32), "secret", false, false},
+		{"argon2id missing key", argon2Hasher, "$argon2id$v=19$m=64,t=1,p=1$MDEyMzQ1Njc4OWFiY2RlZg", "secret", false, false},
+		{"argon2id empty key", argon2Hasher, "$argon2id$v=19$m=64,t=1,p=1$MDEyMzQ1Njc4OWFiY2RlZg$", "secret", false, false},
+		{"argon2id bad parameters", argon2Hasher, "$argon2id$v=19$memory=64$MDEyMzQ1Njc4OWFiY2RlZg$AAAA", "secret", false, false},
+		{"argon2id bad salt", argon2Hasher, "$argon2id$v=19$m=64,t=1,p=1$!!!$AAAA", "secret", false, false},
+		{"bcrypt fallback", argon2Hasher, mustHash(t, bcryptHasher, "secret"), "secret", true, true},
+		{"bcrypt fallback wrong password", argon2Hasher, mustHash(t, bcryptHasher, "secret"), "Secret", false, false},
+		{"bcrypt current", bcryptHasher, mustHash(t, bcryptHasher, "secret"), "secret", true, false},
+		{"bcrypt other cost", bcryptHasher, bcryptHash(t, "secret", bcrypt.MinCost+1), "secret", true, true},
+		{"bcrypt $2y$ prefix", bcryptHasher, "$2y$" + mustHash(t, bcryptHasher, "secret")[4:], "secret", true, false},
+		{"argon2id under bcrypt", bcryptHasher, mustHash(t, argon2Hasher, "secret"), "secret", true, true},
+		{"empty hash", argon2Hasher, "", "", false, false},
+		{"unknown algorithm", argon2Hasher, "$1$salt$hash", "secret", false, false},
+		{"plain text", argon2Hasher, "secret", "secret", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, rehash := tt.hasher.Verify(tt.hash, tt.password)
+			if match != tt.wantMatch || rehash != tt.wantRehash {
+				t.Errorf("Verify() = (%v, %v), want (%v, %v)", match, rehash, tt.wantMatch, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestNewPasswordHasherRejectsInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name       string
+		hashConfig config.PasswordHashConfig
+	}{
+		{"unknown algorithm", config.PasswordHashConfig{Algorithm: "md5"}},
+		{"argon2id without memory", config.PasswordHashConfig{Algorithm: HashArgon2id, Argon2Time: 1, Argon2Threads: 1}},
+		{"argon2id without time", config.PasswordHashConfig{Algorithm: HashArgon2id, Argon2Memory: 64, Argon2Threads: 1}},
+		{"argon2id without threads", config.PasswordHashConfig{Algorithm: HashArgon2id, Argon2Memory: 64, Argon2Time: 1}},
+		{"bcrypt cost too low", config.PasswordHashConfig{Algorithm: HashBcrypt, BcryptCost: bcrypt.MinCost - 1}},
+		{"bcrypt cost too high", config.PasswordHashConfig{Algorithm: HashBcrypt, BcryptCost: bcrypt.MaxCost + 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPasswordHasher(&tt.hashConfig); err == nil {
+				t.Error("NewPasswordHasher() succeeded, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"sensory-navigator/config"
+)
+
+// Codes of the password requirements, stable for clients to translate.
+const (
+	PasswordTooShort     = "too_short"
+	PasswordTooLong      = "too_long"
+	PasswordTooWeak      = "too_weak"
+	PasswordPersonalInfo = "contains_personal_info"
+	PasswordBreached     = "breached"
+)
+
+// minIdentifierLength keeps very short names from rejecting most passwords.
+const minIdentifierLength = 3
+
+// PasswordViolation is one requirement a password does not meet.
+type PasswordViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyError is returned when a new password is rejected. Field
+// is the request field that held the password.
+type PasswordPolicyError struct {
+	Field      string
+	Violations []PasswordViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return "password does not meet the requirements"
+}
+
+// PasswordPolicy checks new passwords for length, estimated strength,
+// personal information and, if a corpus is configured, known breaches.
+type PasswordPolicy struct {
+	config   *config.PasswordPolicyConfig
+	breached *breachedPasswords
+}
+
+func NewPasswordPolicy(policyConfig *config.PasswordPolicyConfig) (*PasswordPolicy, error) {
+	policy := &PasswordPolicy{config: policyConfig}
+	if policyConfig.BreachedPasswordsDir == "" {
+		return policy, nil
+	}
+
+	info, err := os.Stat(policyConfig.BreachedPasswordsDir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New(policyConfig.BreachedPasswordsDir + " is not a directory")
+	}
+
+	policy.breached = &breachedPasswords{
+		dir:      policyConfig.BreachedPasswordsDir,
+		minCount: policyConfig.BreachedMinCount,
+	}
+	return policy, nil
+}
+
+// Validate returns a *PasswordPolicyError listing every unmet requirement,
+// or nil if the password is acceptable. identifiers are the email address
+// and name of the account, which the password must not contain.
+func (p *PasswordPolicy) Validate(field, password string, identifiers ...string) error {
+	var violations []PasswordViolation
+
+	if utf8.RuneCountInString(password) < p.config.MinLength {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooShort,
+			Message: fmt.Sprintf("password must be at least %d characters long", p.config.MinLength),
+		})
+	}
+	if p.config.MaxLength > 0 && len(password) > p.config.MaxLength {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooLong,
+			Message: fmt.Sprintf("password must be at most %d bytes long", p.config.MaxLength),
+		})
+	}
+	if estimateEntropy(password) < p.config.MinEntropy {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordTooWeak,
+			Message: "password is too easy to guess; make it longer or mix letters, digits and symbols",
+		})
+	}
+	if containsIdentifier(password, identifiers) {
+		violations = append(violations, PasswordViolation{
+			Code:    PasswordPersonalInfo,
+			Message: "password must not contain your email address or name",
+		})
+	}
+
+	if p.breached != nil {
+		breached, err := p.breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if breached {
+			violations = append(violations, PasswordViolation{
+				Code:    PasswordBreached,
+				Message: "this password has appeared in a data breach; choose a different one",
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Field: field, Violations: violations}
+	}
+	return nil
+}
+
+// estimateEntropy approximates the strength of a password in bits as its
+// length times log2 of the size of the character classes it uses.
+// Characters repeating or continuing the previous one (aaa, abc, 321)
+// count half.
+func estimateEntropy(password string) float64 {
+	var lower, upper, digit, other bool
+	var length float64
+	var prev rune
+
+	for i, r := range []rune(password) {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+
+		if diff := r - prev; i > 0 && diff >= -1 && diff <= 1 {
+			length += 0.5
+		} else {
+			length++
+		}
+		prev = r
+	}
+
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if other {
+		pool += 33
+	}
+	if pool == 0 {
+		return 0
+	}
+	return length * math.Log2(float64(pool))
+}
+
+// containsIdentifier reports whether the password contains one of the
+// identifiers, the local part of an email address or a word of a name,
+// ignoring case.
+func containsIdentifier(password string, identifiers []string) bool {
+	password = strings.ToLower(password)
+
+	var parts []string
+	for _, identifier := range identifiers {
+		identifier = strings.ToLower(identifier)
+		parts = append(parts, identifier)
+		if local, _, found := strings.Cut(identifier, "@"); found {
+			parts = append(parts, local)
+		}
+		parts = append(parts, strings.Fields(identifier)...)
+	}
+
+	for _, part := range parts {
+		if utf8.RuneCountInString(part) >= minIdentifierLength && strings.Contains(password, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// breachedPasswords looks passwords up in a local copy of a breach corpus
+// split by SHA-1 prefix, so only one small file is read per check and the
+// password never leaves the server.
+type breachedPasswords struct {
+	dir      string
+	minCount int
+}
+
+func (b *breachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(b.dir, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		// The corpus may be partial
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineSuffix, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !strings.EqualFold(lineSuffix, suffix) {
+			continue
+		}
+
+		// Lines without a count still mark the password as breached
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			n = 1
+		}
+		return n >= b.minCount, nil
+	}
+	return false, scanner.Err()
+}
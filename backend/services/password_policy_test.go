@@ -0,0 +1,122 @@
+package services
+
+import (
+	"crypto/sha1"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sensory-navigator/config"
+	"sensory-navigator/models"
+	"sensory-navigator/repository"
+)
+
+func violationCodes(err error) []string {
+	var policyErr *PasswordPolicyError
+	if !errors.As(err, &policyErr) {
+		return nil
+	}
+	codes := make([]string, 0, len(policyErr.Violations))
+	for _, v := range policyErr.Violations {
+		codes = append(codes, v.Code)
+	}
+	return codes
+}
+
+// writeBreachCorpus stores password in dir in the Pwned Passwords range
+// format with count occurrences.
+func writeBreachCorpus(t *testing.T, dir, password, count string) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	line := hash[5:] + ":" + count + "\n"
+	if err := os.WriteFile(filepath.Join(dir, hash[:5]+".txt"), []byte("0000000000000000000000000000000000A:3\n"+line), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	dir := t.TempDir()
+	writeBreachCorpus(t, dir, "Tr0ub4dor&3xyz", "12")
+	writeBreachCorpus(t, dir, "Rarely!Leaked42", "1")
+
+	policy, err := NewPasswordPolicy(&config.PasswordPolicyConfig{
+		MinLength:            8,
+		MaxLength:            72,
+		MinEntropy:           40,
+		BreachedPasswordsDir: dir,
+		BreachedMinCount:     2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		password  string
+		wantCodes []string
+	}{
+		{name: "acceptable", password: "correct-Horse-battery-7"},
+		{name: "too short", password: "x9!Q", wantCodes: []string{PasswordTooShort, PasswordTooWeak}},
+		{name: "too long", password: strings.Repeat("aB3$", 19), wantCodes: []string{PasswordTooLong}},
+		{name: "sequence counts half", password: "abcdefghijk", wantCodes: []string{PasswordTooWeak}},
+		{name: "contains email local part", password: "Marina-Sunset-42", wantCodes: []string{PasswordPersonalInfo}},
+		{name: "contains name word", password: "Petrova-Autumn-81", wantCodes: []string{PasswordPersonalInfo}},
+		{name: "breached", password: "Tr0ub4dor&3xyz", wantCodes: []string{PasswordBreached}},
+		{name: "breached below the count", password: "Rarely!Leaked42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate("new_password", tt.password, "marina@example.com", "Anna Petrova")
+			if got := violationCodes(err); !reflect.DeepEqual(got, tt.wantCodes) {
+				t.Errorf("Validate() violations = %v, want %v (err = %v)", got, tt.wantCodes, err)
+			}
+		})
+	}
+}
+
+func TestResetPasswordKeepsLinkForRejectedPassword(t *testing.T) {
+	tests := []struct {
+		name        string
+		password    string
+		tokenValid  bool
+		wantErr     bool
+		wantConsume bool
+	}{
+		{name: "accepted", password: "correct-Horse-battery-7", tokenValid: true, wantConsume: true},
+		{name: "rejected by the policy", password: "short", tokenValid: true, wantErr: true},
+		{name: "invalid link", password: "correct-Horse-battery-7", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB()
+			if tt.tokenValid {
+				fake.onQuery("SELECT user_id FROM password_reset_tokens", []string{"user_id"}, []driver.Value{int64(42)})
+				fake.onQuery("UPDATE password_reset_tokens", []string{"user_id"}, []driver.Value{int64(42)})
+			}
+			fake.onQuery("FROM users WHERE id = $1", userColumns, userRow(t, "old-password"))
+
+			s := newRevocationTestService(t, newMemoryRevocationStore())
+			s.userRepo = repository.NewUserRepository(db)
+			s.apiKeyRepo = repository.NewAPIKeyRepository(db)
+			s.auditor = repository.NewAuditEventRepository(db)
+			s.throttle = newTestLoginThrottle()
+			s.hasher = newTestHasher(t, testBcryptConfig)
+			s.passwords, _ = NewPasswordPolicy(&config.PasswordPolicyConfig{MinLength: 8})
+
+			err := s.ResetPassword("token", tt.password, &models.ClientInfo{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResetPassword() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if consumed := fake.ran("UPDATE password_reset_tokens") > 0; consumed != tt.wantConsume {
+				t.Errorf("token consumed = %v, want %v", consumed, tt.wantConsume)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"sensory-navigator/models"
+)
+
+// fitFlagShare is the share of ratings within tolerance below which a
+// place is flagged: most visitors found it beyond what the user can bear.
+const fitFlagShare = 0.5
+
+var fitFlags = map[string]models.PlaceFitFlag{
+	models.DimensionSensory:       {Code: models.FitTooIntense, Message: "likely too overwhelming for you"},
+	models.DimensionLighting:      {Code: models.FitTooBright, Message: "likely too bright for you"},
+	models.DimensionSoundLevel:    {Code: models.FitTooLoud, Message: "likely too loud for you"},
+	models.DimensionCrowding:      {Code: models.FitTooCrowded, Message: "likely too crowded for you"},
+	models.DimensionAccessibility: {Code: models.FitNotAccessible, Message: "likely not accessible enough for you"},
+}
+
+var dimensionNames = map[string]string{
+	models.DimensionSensory:       "overall sensory load",
+	models.DimensionLighting:      "lighting",
+	models.DimensionSoundLevel:    "sound level",
+	models.DimensionCrowding:      "crowding",
+	models.DimensionAccessibility: "accessibility",
+}
+
+// FitPlace scores a place for a user. For every dimension the user set it
+// takes the share of the place's ratings within their tolerance; the
+// score is the weighted mean of those shares, scaled to 0-100. The driver
+// is the dimension that lowered the score most.
+func FitPlace(prefs []*models.SensoryPreference, stats []*models.SensoryStats) *models.PlaceFit {
+	byDimension := make(map[string]*models.SensoryStats, len(stats))
+	for _, s := range stats {
+		byDimension[s.Dimension] = s
+	}
+
+	fit := &models.PlaceFit{
+		Flags:      []models.PlaceFitFlag{},
+		Dimensions: make([]models.DimensionFit, 0, len(prefs)),
+	}
+
+	var weighted, totalWeight, worstLoss float64
+	worst := -1
+	for _, pref := range prefs {
+		dimension := models.DimensionFit{
+			Dimension: pref.Dimension,
+			Tolerance: pref.Tolerance,
+			Weight:    pref.Weight,
+		}
+
+		if s, ok := byDimension[pref.Dimension]; ok && s.Count > 0 {
+			within := 0
+			for i, n := range s.Histogram {
+				if pref.Accepts(i + 1) {
+					within += int(n)
+				}
+			}
+			share := float64(within) / float64(s.Count)
+			dimension.RatingCount = s.Count
+			dimension.WithinShare = &share
+
+			weighted += float64(pref.Weight) * share
+			totalWeight += float64(pref.Weight)
+
+			if share < fitFlagShare {
+				flag := fitFlags[pref.Dimension]
+				flag.Dimension = pref.Dimension
+				fit.Flags = append(fit.Flags, flag)
+			}
+		}
+
+		fit.Dimensions = append(fit.Dimensions, dimension)
+		if dimension.WithinShare != nil {
+			if loss := float64(pref.Weight) * (1 - *dimension.WithinShare); loss > worstLoss {
+				worstLoss = loss
+				worst = len(fit.Dimensions) - 1
+			}
+		}
+	}
+
+	switch {
+	case totalWeight == 0:
+		fit.Explanation = "no reviews rate the dimensions in your sensory profile yet"
+	case worst < 0:
+		score := 100
+		fit.Score = &score
+		fit.Explanation = "every rating is within your tolerance"
+	default:
+		driver := fit.Dimensions[worst]
+		score := int(math.Round(100 * weighted / totalWeight))
+		fit.Score = &score
+		fit.Driver = driver.Dimension
+		fit.Explanation = fmt.Sprintf("%s lowered the score most: %.0f%% of %d ratings are within your tolerance",
+			dimensionNames[driver.Dimension], 100**driver.WithinShare, driver.RatingCount)
+	}
+
+	return fit
+}
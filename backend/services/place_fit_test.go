@@ -0,0 +1,174 @@
+package services
+
+import (
+	"testing"
+
+	"sensory-navigator/models"
+)
+
+// ratings builds the statistics of a dimension from its histogram.
+func ratings(dimension string, histogram ...int64) *models.SensoryStats {
+	s := &models.SensoryStats{Dimension: dimension, Histogram: histogram}
+	for i, n := range histogram {
+		rating := int64(i + 1)
+		s.Count += int(n)
+		s.Sum += n * rating
+		s.SumSquares += n * rating * rating
+	}
+	return s
+}
+
+func pref(dimension string, tolerance, weight int) *models.SensoryPreference {
+	return &models.SensoryPreference{Dimension: dimension, Tolerance: tolerance, Weight: weight}
+}
+
+func TestFitPlace(t *testing.T) {
+	tests := []struct {
+		name        string
+		prefs       []*models.SensoryPreference
+		stats       []*models.SensoryStats
+		wantScore   *int
+		wantDriver  string
+		wantFlags   []string
+		wantRated   []string
+		explanation string
+	}{
+		{
+			name:        "no sensory profile",
+			stats:       []*models.SensoryStats{ratings(models.DimensionSoundLevel, 1, 1, 1, 1, 1)},
+			explanation: "no reviews rate the dimensions in your sensory profile yet",
+		},
+		{
+			name:        "no ratings at all",
+			prefs:       []*models.SensoryPreference{pref(models.DimensionSoundLevel, 2, 5)},
+			explanation: "no reviews rate the dimensions in your sensory profile yet",
+		},
+		{
+			name:        "only dimensions outside the profile are rated",
+			prefs:       []*models.SensoryPreference{pref(models.DimensionSoundLevel, 2, 5)},
+			stats:       []*models.SensoryStats{ratings(models.DimensionLighting, 0, 0, 0, 0, 4)},
+			explanation: "no reviews rate the dimensions in your sensory profile yet",
+		},
+		{
+			name:        "rated dimension without ratings",
+			prefs:       []*models.SensoryPreference{pref(models.DimensionSoundLevel, 2, 5)},
+			stats:       []*models.SensoryStats{ratings(models.DimensionSoundLevel, 0, 0, 0, 0, 0)},
+			explanation: "no reviews rate the dimensions in your sensory profile yet",
+		},
+		{
+			name: "missing dimension does not count",
+			prefs: []*models.SensoryPreference{
+				pref(models.DimensionSoundLevel, 2, 5),
+				pref(models.DimensionCrowding, 3, 1),
+			},
+			stats:       []*models.SensoryStats{ratings(models.DimensionCrowding, 1, 1, 1, 1, 0)},
+			wantScore:   intPtr(75),
+			wantDriver:  models.DimensionCrowding,
+			wantRated:   []string{models.DimensionCrowding},
+			explanation: "crowding lowered the score most: 75% of 4 ratings are within your tolerance",
+		},
+		{
+			name:        "every rating within tolerance",
+			prefs:       []*models.SensoryPreference{pref(models.DimensionSoundLevel, 3, 3)},
+			stats:       []*models.SensoryStats{ratings(models.DimensionSoundLevel, 1, 2, 1, 0, 0)},
+			wantScore:   intPtr(100),
+			wantRated:   []string{models.DimensionSoundLevel},
+			explanation: "every rating is within your tolerance",
+		},
+		{
+			name:        "nothing within tolerance is flagged",
+			prefs:       []*models.SensoryPreference{pref(models.DimensionSoundLevel, 2, 3)},
+			stats:       []*models.SensoryStats{ratings(models.DimensionSoundLevel, 0, 0, 1, 1, 2)},
+			wantScore:   intPtr(0),
+			wantDriver:  models.DimensionSoundLevel,
+			wantFlags:   []string{models.FitTooLoud},
+			wantRated:   []string{models.DimensionSoundLevel},
+			explanation: "sound level lowered the score most: 0% of 4 ratings are within your tolerance",
+		},
+		{
+			name:        "half within tolerance is not flagged",
+			prefs:       []*models.SensoryPreference{pref(models.DimensionAccessibility, 4, 2)},
+			stats:       []*models.SensoryStats{ratings(models.DimensionAccessibility, 2, 0, 0, 1, 1)},
+			wantScore:   intPtr(50),
+			wantDriver:  models.DimensionAccessibility,
+			wantRated:   []string{models.DimensionAccessibility},
+			explanation: "accessibility lowered the score most: 50% of 4 ratings are within your tolerance",
+		},
+		{
+			name: "weighted mean and heaviest loss",
+			prefs: []*models.SensoryPreference{
+				pref(models.DimensionCrowding, 2, 1),
+				pref(models.DimensionSoundLevel, 2, 5),
+				pref(models.DimensionLighting, 1, 2),
+			},
+			stats: []*models.SensoryStats{
+				ratings(models.DimensionCrowding, 0, 1, 3, 0, 0),
+				ratings(models.DimensionSoundLevel, 1, 1, 1, 1, 0),
+			},
+			// (1*0.25 + 5*0.5) / 6
+			wantScore:   intPtr(46),
+			wantDriver:  models.DimensionSoundLevel,
+			wantFlags:   []string{models.FitTooCrowded},
+			wantRated:   []string{models.DimensionCrowding, models.DimensionSoundLevel},
+			explanation: "sound level lowered the score most: 50% of 4 ratings are within your tolerance",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fit := FitPlace(tt.prefs, tt.stats)
+
+			switch {
+			case tt.wantScore == nil && fit.Score != nil:
+				t.Errorf("Score = %d, want nil", *fit.Score)
+			case tt.wantScore != nil && fit.Score == nil:
+				t.Errorf("Score = nil, want %d", *tt.wantScore)
+			case tt.wantScore != nil && *fit.Score != *tt.wantScore:
+				t.Errorf("Score = %d, want %d", *fit.Score, *tt.wantScore)
+			}
+			if fit.Driver != tt.wantDriver {
+				t.Errorf("Driver = %q, want %q", fit.Driver, tt.wantDriver)
+			}
+			if fit.Explanation != tt.explanation {
+				t.Errorf("Explanation = %q, want %q", fit.Explanation, tt.explanation)
+			}
+
+			var flags []string
+			for _, flag := range fit.Flags {
+				flags = append(flags, flag.Code)
+			}
+			if !equalStrings(flags, tt.wantFlags) {
+				t.Errorf("Flags = %v, want %v", flags, tt.wantFlags)
+			}
+
+			// Every dimension of the profile is listed, rated or not
+			if len(fit.Dimensions) != len(tt.prefs) {
+				t.Fatalf("got %d dimensions, want %d", len(fit.Dimensions), len(tt.prefs))
+			}
+			var rated []string
+			for _, dimension := range fit.Dimensions {
+				if dimension.WithinShare != nil {
+					rated = append(rated, dimension.Dimension)
+				}
+			}
+			if !equalStrings(rated, tt.wantRated) {
+				t.Errorf("rated dimensions = %v, want %v", rated, tt.wantRated)
+			}
+		})
+	}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimitError is returned when a key has used up its requests.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return "too many requests, try again later"
+}
+
+// maxRateLimitKeys bounds the counters kept before idle ones are pruned.
+const maxRateLimitKeys = 10000
+
+// RateLimiter allows a number of requests per key within a window. The
+// window restarts once a key has been idle for its duration.
+type RateLimiter struct {
+	window time.Duration
+
+	mu       sync.Mutex
+	counters map[string]*rateCounter
+}
+
+type rateCounter struct {
+	count  int
+	lastAt time.Time
+}
+
+func NewRateLimiter(window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		window:   window,
+		counters: make(map[string]*rateCounter),
+	}
+}
+
+// Allow counts a request for key and returns a *RateLimitError if limit
+// requests were already made within the window. Refused requests are not
+// counted.
+func (l *RateLimiter) Allow(key string, limit int) error {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	counter, ok := l.counters[key]
+	if !ok || now.Sub(counter.lastAt) > l.window {
+		if len(l.counters) >= maxRateLimitKeys {
+			l.prune(now)
+		}
+		counter = &rateCounter{}
+		l.counters[key] = counter
+	}
+
+	if counter.count >= limit {
+		return &RateLimitError{RetryAfter: counter.lastAt.Add(l.window).Sub(now)}
+	}
+	counter.count++
+	counter.lastAt = now
+	return nil
+}
+
+// prune drops counters idle for longer than the window. Callers must hold
+// mu.
+func (l *RateLimiter) prune(now time.Time) {
+	for key, counter := range l.counters {
+		if now.Sub(counter.lastAt) > l.window {
+			delete(l.counters, key)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    int
+		requests int
+		idle     time.Duration
+		wantErr  bool
+	}{
+		{name: "under limit", limit: 3, requests: 2},
+		{name: "at limit", limit: 3, requests: 3},
+		{name: "over limit", limit: 3, requests: 4, wantErr: true},
+		{name: "window restarts after idle", limit: 3, requests: 3, idle: 150 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter(100 * time.Millisecond)
+
+			var err error
+			for i := 0; i < tt.requests; i++ {
+				if err = limiter.Allow("key", tt.limit); err != nil {
+					break
+				}
+			}
+			if tt.idle > 0 {
+				time.Sleep(tt.idle)
+				err = limiter.Allow("key", tt.limit)
+			}
+
+			var rateLimitErr *RateLimitError
+			if got := errors.As(err, &rateLimitErr); got != tt.wantErr {
+				t.Fatalf("Allow() error = %v, want rate limit error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && (rateLimitErr.RetryAfter <= 0 || rateLimitErr.RetryAfter > 100*time.Millisecond) {
+				t.Errorf("RetryAfter = %v, want within the window", rateLimitErr.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestRateLimiterKeysAreIndependent(t *testing.T) {
+	limiter := NewRateLimiter(time.Hour)
+
+	if err := limiter.Allow("a", 1); err != nil {
+		t.Fatalf("first request for a: %v", err)
+	}
+	if err := limiter.Allow("a", 1); err == nil {
+		t.Fatal("second request for a was allowed")
+	}
+	if err := limiter.Allow("b", 1); err != nil {
+		t.Errorf("request for b was limited by a: %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+
+	"sensory-navigator/models"
+	"sensory-navigator/repository"
+)
+
+var refreshTokenColumns = []string{"id", "user_id", "family_id", "expires_at", "revoked", "created_at"}
+
+func refreshTokenRow(revoked bool) []driver.Value {
+	now := time.Now()
+	return []driver.Value{int64(7), int64(42), "family", now.Add(time.Hour), revoked, now}
+}
+
+func TestDetectRefreshTokenReuse(t *testing.T) {
+	tests := []struct {
+		name        string
+		row         []driver.Value
+		lookupErr   error
+		familyAlive int64
+		wantErr     bool
+		wantRevoke  bool
+		wantRecord  bool
+		// wantDenied is whether the family's access tokens are denied
+		wantDenied bool
+	}{
+		{name: "unknown token"},
+		{name: "expired token", row: refreshTokenRow(false)},
+		{name: "revoked token reused", row: refreshTokenRow(true), familyAlive: 1, wantRevoke: true, wantRecord: true, wantDenied: true},
+		{name: "family already ended", row: refreshTokenRow(true), wantRevoke: true},
+		{name: "lookup fails", lookupErr: errors.New("connection reset"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, fake := newFakeDB()
+			switch {
+			case tt.lookupErr != nil:
+				fake.onError("FROM refresh_tokens", tt.lookupErr)
+			case tt.row != nil:
+				fake.onQuery("FROM refresh_tokens", refreshTokenColumns, tt.row)
+			}
+			fake.onExec("WHERE family_id = $1", tt.familyAlive)
+			store := newMemoryRevocationStore()
+			s := newRevocationTestService(t, store)
+			s.userRepo = repository.NewUserRepository(db)
+			s.auditor = repository.NewAuditEventRepository(db)
+
+			err := s.detectRefreshTokenReuse("token", &models.ClientInfo{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("detectRefreshTokenReuse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			revoked := fake.ran("WHERE family_id = $1") > 0
+			if revoked != tt.wantRevoke {
+				t.Errorf("family revoked = %v, want %v", revoked, tt.wantRevoke)
+			}
+			recorded := fake.ran("INSERT INTO audit_events") > 0
+			if recorded != tt.wantRecord {
+				t.Errorf("reuse recorded = %v, want %v", recorded, tt.wantRecord)
+			}
+			if denied, _ := store.IsDenied("family"); denied != tt.wantDenied {
+				t.Errorf("family denied = %v, want %v", denied, tt.wantDenied)
+			}
+			// Other sessions of the user keep their access tokens
+			if generation, _ := store.Generation(42); generation != 0 {
+				t.Errorf("user generation = %d, want 0", generation)
+			}
+		})
+	}
+}
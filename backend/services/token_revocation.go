@@ -0,0 +1,139 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenRevocationStore keeps what is needed to revoke access tokens before
+// they expire: a per-user generation that is bumped to invalidate all of a
+// user's tokens, and a denylist of single token IDs. Session (refresh token
+// family) IDs share the denylist, which revokes every token of a session.
+type TokenRevocationStore interface {
+	Generation(userID int64) (int64, error)
+	BumpGeneration(userID int64) (int64, error)
+	Deny(jti string, expiresAt time.Time) error
+	IsDenied(jti string) (bool, error)
+}
+
+// maxCachedTokenEntries bounds each cache map before stale entries are
+// pruned.
+const maxCachedTokenEntries = 10000
+
+// TokenRevocationCache answers the checks made on every authenticated
+// request from memory for up to ttl. Revocations made by this instance are
+// visible immediately; those made by other instances once the cached entry
+// expires.
+type TokenRevocationCache struct {
+	store TokenRevocationStore
+	ttl   time.Duration
+
+	mu          sync.Mutex
+	generations map[int64]cachedGeneration
+	denied      map[string]cachedDenial
+}
+
+type cachedGeneration struct {
+	generation int64
+	fetchedAt  time.Time
+}
+
+type cachedDenial struct {
+	denied    bool
+	fetchedAt time.Time
+}
+
+func NewTokenRevocationCache(store TokenRevocationStore, ttl time.Duration) *TokenRevocationCache {
+	return &TokenRevocationCache{
+		store:       store,
+		ttl:         ttl,
+		generations: make(map[int64]cachedGeneration),
+		denied:      make(map[string]cachedDenial),
+	}
+}
+
+func (c *TokenRevocationCache) Generation(userID int64) (int64, error) {
+	c.mu.Lock()
+	entry, ok := c.generations[userID]
+	c.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < c.ttl {
+		return entry.generation, nil
+	}
+
+	generation, err := c.store.Generation(userID)
+	if err != nil {
+		return 0, err
+	}
+	c.setGeneration(userID, generation)
+	return generation, nil
+}
+
+// RevokeUser invalidates every access token issued to the user so far.
+func (c *TokenRevocationCache) RevokeUser(userID int64) error {
+	generation, err := c.store.BumpGeneration(userID)
+	if err != nil {
+		return err
+	}
+	c.setGeneration(userID, generation)
+	return nil
+}
+
+// RevokeToken invalidates a single access token until it expires, or all
+// tokens of a session when given its ID.
+func (c *TokenRevocationCache) RevokeToken(jti string, expiresAt time.Time) error {
+	if err := c.store.Deny(jti, expiresAt); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.denied[jti] = cachedDenial{denied: true, fetchedAt: time.Now()}
+	return nil
+}
+
+func (c *TokenRevocationCache) IsDenied(jti string) (bool, error) {
+	c.mu.Lock()
+	entry, ok := c.denied[jti]
+	c.mu.Unlock()
+	// A denial is final, only negative answers need refreshing
+	if ok && (entry.denied || time.Since(entry.fetchedAt) < c.ttl) {
+		return entry.denied, nil
+	}
+
+	denied, err := c.store.IsDenied(jti)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.denied) >= maxCachedTokenEntries {
+		c.pruneDenied()
+	}
+	c.denied[jti] = cachedDenial{denied: denied, fetchedAt: time.Now()}
+	return denied, nil
+}
+
+func (c *TokenRevocationCache) setGeneration(userID, generation int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.generations) >= maxCachedTokenEntries {
+		for id, entry := range c.generations {
+			if time.Since(entry.fetchedAt) >= c.ttl {
+				delete(c.generations, id)
+			}
+		}
+	}
+	c.generations[userID] = cachedGeneration{generation: generation, fetchedAt: time.Now()}
+}
+
+// pruneDenied drops entries older than ttl; denied tokens among them are
+// looked up again if presented. Callers must hold mu.
+func (c *TokenRevocationCache) pruneDenied() {
+	for jti, entry := range c.denied {
+		if time.Since(entry.fetchedAt) >= c.ttl {
+			delete(c.denied, jti)
+		}
+	}
+}
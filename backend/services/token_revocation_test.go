@@ -0,0 +1,131 @@
+package services
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"sensory-navigator/config"
+	"sensory-navigator/models"
+)
+
+// memoryRevocationStore is a TokenRevocationStore kept in memory.
+type memoryRevocationStore struct {
+	mu          sync.Mutex
+	generations map[int64]int64
+	denied      map[string]time.Time
+}
+
+func newMemoryRevocationStore() *memoryRevocationStore {
+	return &memoryRevocationStore{
+		generations: make(map[int64]int64),
+		denied:      make(map[string]time.Time),
+	}
+}
+
+func (m *memoryRevocationStore) Generation(userID int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.generations[userID], nil
+}
+
+func (m *memoryRevocationStore) BumpGeneration(userID int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.generations[userID]++
+	return m.generations[userID], nil
+}
+
+func (m *memoryRevocationStore) Deny(jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.denied[jti] = expiresAt
+	return nil
+}
+
+func (m *memoryRevocationStore) IsDenied(jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.denied[jti]
+	return ok, nil
+}
+
+// newRevocationTestService returns an AuthService that signs HS256 access
+// tokens and keeps revocations in store.
+func newRevocationTestService(t *testing.T, store TokenRevocationStore) *AuthService {
+	jwtConfig := &config.JWTConfig{
+		Secret:       "test-secret-that-is-long-enough-for-hs256",
+		AccessExpiry: 15 * time.Minute,
+	}
+	keys, err := LoadKeySet(jwtConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &AuthService{
+		config:     jwtConfig,
+		keys:       keys,
+		revocation: NewTokenRevocationCache(store, time.Minute),
+	}
+}
+
+func TestValidateAccessTokenRevocation(t *testing.T) {
+	user := &models.User{ID: 42, Role: models.RoleUser}
+
+	tests := []struct {
+		name    string
+		revoke  func(s *AuthService, claims *Claims) error
+		wantErr error
+	}{
+		{
+			name:   "not revoked",
+			revoke: func(*AuthService, *Claims) error { return nil },
+		},
+		{
+			name:    "user revoked",
+			revoke:  func(s *AuthService, _ *Claims) error { return s.RevokeAccessTokens(42) },
+			wantErr: ErrTokenRevoked,
+		},
+		{
+			name: "token denied",
+			revoke: func(s *AuthService, claims *Claims) error {
+				return s.revocation.RevokeToken(claims.ID, claims.ExpiresAt.Time)
+			},
+			wantErr: ErrTokenRevoked,
+		},
+		{
+			name:    "session denied",
+			revoke:  func(s *AuthService, _ *Claims) error { return s.revokeSessionAccessTokens("family") },
+			wantErr: ErrTokenRevoked,
+		},
+		{
+			name:   "other session denied",
+			revoke: func(s *AuthService, _ *Claims) error { return s.revokeSessionAccessTokens("other-family") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newRevocationTestService(t, newMemoryRevocationStore())
+
+			token, err := s.generateAccessToken(user, "family")
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims := &Claims{}
+			if _, err := jwt.ParseWithClaims(token, claims, s.keys.Keyfunc); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.revoke(s, claims); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = s.ValidateAccessToken(token)
+			if err != tt.wantErr {
+				t.Errorf("ValidateAccessToken() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors,
+// "12345678901234567890", in base32.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC lists 8-digit codes; 6-digit codes are their last six digits.
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		got, err := totpCode(rfc6238Secret, v.unix/totpPeriod)
+		if err != nil {
+			t.Fatalf("totpCode(T=%d): %v", v.unix, err)
+		}
+		if got != v.code {
+			t.Errorf("totpCode(T=%d) = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		step, err := verifyTOTP(rfc6238Secret, v.code, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatalf("verifyTOTP(T=%d): %v", v.unix, err)
+		}
+		if want := v.unix / totpPeriod; step != want {
+			t.Errorf("verifyTOTP(T=%d) = %d, want %d", v.unix, step, want)
+		}
+	}
+
+	// The code of T=59 belongs to step 1
+	const code = "287082"
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+		unix   int64
+		want   int64
+	}{
+		{"current step", rfc6238Secret, code, 59, 1},
+		{"one step late", rfc6238Secret, code, 59 + totpPeriod, 1},
+		{"one step early", rfc6238Secret, code, 59 - totpPeriod, 1},
+		{"two steps late", rfc6238Secret, code, 59 + 2*totpPeriod, -1},
+		{"two steps early", rfc6238Secret, "081804", 1111111109 - 2*totpPeriod, -1},
+		{"spaces are ignored", rfc6238Secret, "287 082", 59, 1},
+		{"lowercase secret", strings.ToLower(rfc6238Secret), code, 59, 1},
+		{"wrong code", rfc6238Secret, "287083", 59, -1},
+		{"empty code", rfc6238Secret, "", 59, -1},
+		{"8-digit code", rfc6238Secret, "94287082", 59, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifyTOTP(tt.secret, tt.code, time.Unix(tt.unix, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("verifyTOTP() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := verifyTOTP("not base32!", code, time.Unix(59, 0)); err == nil {
+		t.Error("verifyTOTP() with an invalid secret: want an error")
+	}
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"sensory-navigator/models"
+)
+
+const (
+	purposeLoginChallenge = "login_challenge"
+	recoveryCodeCount     = 10
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotSetUp       = errors.New("two-factor authentication has not been set up")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+	ErrInvalidLoginChallenge   = errors.New("invalid or expired login challenge")
+)
+
+// LoginResult is the outcome of a password login. When the user has
+// two-factor authentication enabled, Tokens is nil and ChallengeToken must
+// be exchanged together with a code through LoginTwoFactor.
+type LoginResult struct {
+	User           *models.User
+	Tokens         *TokenPair
+	ChallengeToken string
+}
+
+// SetupTwoFactor starts enrollment with a fresh secret. It only takes
+// effect once confirmed with a code from the authenticator app.
+func (s *AuthService) SetupTwoFactor(userID int64) (*models.TwoFactorSetupResponse, error) {
+	enabled, err := s.twoFactorRepo.IsEnabled(userID)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.twoFactorRepo.SavePendingTOTP(userID, secret); err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorSetupResponse{
+		Secret:     secret,
+		OTPAuthURI: totpURI(s.authConfig.TOTPIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication and returns the
+// recovery codes. They are shown to the user only this once.
+func (s *AuthService) ConfirmTwoFactor(userID int64, code string, client *models.ClientInfo) ([]string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	totp, err := s.twoFactorRepo.FindTOTP(userID)
+	if err == sql.ErrNoRows {
+		return nil, ErrTwoFactorNotSetUp
+	}
+	if err != nil {
+		return nil, err
+	}
+	if totp.ConfirmedAt.Valid {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	err = s.checkTwoFactorCode(user, client, func() (bool, error) {
+		return s.useTOTPCode(totp, code)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.twoFactorRepo.ConfirmTOTP(userID); err != nil {
+		return nil, err
+	}
+
+	s.audit(models.EventTwoFactorEnabled, userID, client, nil)
+
+	return s.issueRecoveryCodes(userID)
+}
+
+// DisableTwoFactor requires both the password and a current code, so a
+// stolen session alone cannot turn the protection off. Failures of either
+// count towards the login throttle.
+func (s *AuthService) DisableTwoFactor(userID int64, password, code string, client *models.ClientInfo) error {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkCurrentPassword(user, password, client); err != nil {
+		return err
+	}
+
+	err = s.checkTwoFactorCode(user, client, func() (bool, error) {
+		return s.verifySecondFactor(userID, code)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := s.twoFactorRepo.Delete(userID); err != nil {
+		return err
+	}
+	s.audit(models.EventTwoFactorDisabled, userID, client, nil)
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes. It requires a code
+// from the authenticator app.
+func (s *AuthService) RegenerateRecoveryCodes(userID int64, code string, client *models.ClientInfo) ([]string, error) {
+	user, err := s.userRepo.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	totp, err := s.twoFactorRepo.FindTOTP(userID)
+	if err == sql.ErrNoRows || (err == nil && !totp.ConfirmedAt.Valid) {
+		return nil, ErrTwoFactorNotSetUp
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkTwoFactorCode(user, client, func() (bool, error) {
+		return s.useTOTPCode(totp, code)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit(models.EventRecoveryCodesRegenerated, userID, client, nil)
+	return s.issueRecoveryCodes(userID)
+}
+
+// LoginTwoFactor completes a login started with a password. Failed codes
+// count towards the same lockout as failed passwords.
+func (s *AuthService) LoginTwoFactor(challengeToken, code string, client *models.ClientInfo) (*LoginResult, error) {
+	claims, err := s.parseActionToken(challengeToken, purposeLoginChallenge)
+	if err != nil {
+		return nil, ErrInvalidLoginChallenge
+	}
+
+	user, err := s.userRepo.FindByID(claims.UserID)
+	if err != nil || user.Email != claims.Email {
+		return nil, ErrInvalidLoginChallenge
+	}
+
+	err = s.checkTwoFactorCode(user, client, func() (bool, error) {
+		return s.verifySecondFactor(user.ID, code)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(user, client)
+}
+
+// checkTwoFactorCode runs check, which verifies a code of the user. Failed
+// codes count towards the login throttle like failed passwords, so codes
+// cannot be guessed with a stolen challenge or session either.
+func (s *AuthService) checkTwoFactorCode(user *models.User, client *models.ClientInfo, check func() (bool, error)) error {
+	if err := s.throttle.Attempt(user.Email, client.IPAddress); err != nil {
+		return err
+	}
+
+	ok, err := check()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		s.record(NewAuditEvent(models.EventLoginFailed, 0, user.ID, client, map[string]interface{}{
+			"email":  user.Email,
+			"reason": "two_factor_code",
+		}))
+		return ErrInvalidTwoFactorCode
+	}
+
+	return s.throttle.RecordSuccess(user.Email, client.IPAddress)
+}
+
+// verifySecondFactor accepts a TOTP code or an unused recovery code.
+func (s *AuthService) verifySecondFactor(userID int64, code string) (bool, error) {
+	totp, err := s.twoFactorRepo.FindTOTP(userID)
+	if err == sql.ErrNoRows || (err == nil && !totp.ConfirmedAt.Valid) {
+		return false, ErrTwoFactorNotSetUp
+	}
+	if err != nil {
+		return false, err
+	}
+
+	ok, err := s.useTOTPCode(totp, code)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	return s.twoFactorRepo.UseRecoveryCode(userID, normalizeRecoveryCode(code))
+}
+
+// useTOTPCode checks code against the secret and consumes its time step.
+func (s *AuthService) useTOTPCode(totp *models.TOTPSecret, code string) (bool, error) {
+	step, err := verifyTOTP(totp.Secret, code, time.Now())
+	if err != nil || step < 0 {
+		return false, err
+	}
+	return s.twoFactorRepo.UseTOTPStep(totp.UserID, step)
+}
+
+func (s *AuthService) issueRecoveryCodes(userID int64) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+	}
+
+	normalized := make([]string, len(codes))
+	for i, code := range codes {
+		normalized[i] = normalizeRecoveryCode(code)
+	}
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(userID, normalized); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// generateRecoveryCode returns a code like "k7x2m-q9ptd".
+func generateRecoveryCode() (string, error) {
+	bytes := make([]byte, 10)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(bytes))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
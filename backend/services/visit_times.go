@@ -0,0 +1,162 @@
+package services
+
+import (
+	"sort"
+
+	"sensory-navigator/models"
+)
+
+const (
+	// visitWindowHours is how many hours on each side of an hour pool
+	// their reviews with it, as single hours rarely have enough
+	visitWindowHours = 1
+	// minVisitWindowReviews is how many reviews a window needs to be
+	// recommended
+	minVisitWindowReviews = 3
+	maxBestVisitTimes     = 3
+)
+
+// intensityDimensions are the dimensions whose ratings measure how
+// demanding a place is; accessibility does not change with the hour.
+var intensityDimensions = []string{
+	models.DimensionSensory,
+	models.DimensionLighting,
+	models.DimensionSoundLevel,
+	models.DimensionCrowding,
+}
+
+// BestVisitTimes recommends up to three hours of the week to visit. Each
+// hour with reviews is judged together with the hours next to it: by fit
+// for users with a sensory profile, otherwise by load. Recommended hours
+// are far enough apart that their windows do not overlap.
+func BestVisitTimes(buckets []*models.SensoryTimeBucket, prefs []*models.SensoryPreference) []models.BestVisitTime {
+	byHour := make(map[int]*models.SensoryTimeBucket, len(buckets))
+	for _, bucket := range buckets {
+		byHour[bucket.HourOfWeek] = bucket
+	}
+
+	var candidates []models.BestVisitTime
+	for _, bucket := range buckets {
+		reviewCount := 0
+		pooled := make(map[string]*models.SensoryStats)
+		for offset := -visitWindowHours; offset <= visitWindowHours; offset++ {
+			neighbour, ok := byHour[(bucket.HourOfWeek+offset+models.HoursPerWeek)%models.HoursPerWeek]
+			if !ok {
+				continue
+			}
+			reviewCount += neighbour.ReviewCount
+			for _, s := range neighbour.Stats {
+				pooled[s.Dimension] = addStats(pooled[s.Dimension], s)
+			}
+		}
+		if reviewCount < minVisitWindowReviews {
+			continue
+		}
+
+		day, hour := models.SplitHourOfWeek(bucket.HourOfWeek)
+		candidate := models.BestVisitTime{
+			HourOfWeek:  bucket.HourOfWeek,
+			DayOfWeek:   day,
+			Hour:        hour,
+			ReviewCount: reviewCount,
+			Load:        load(pooled),
+		}
+
+		if len(prefs) > 0 {
+			stats := make([]*models.SensoryStats, 0, len(pooled))
+			for _, s := range pooled {
+				stats = append(stats, s)
+			}
+			candidate.FitScore = FitPlace(prefs, stats).Score
+			if candidate.FitScore == nil {
+				continue
+			}
+		} else if candidate.Load == nil {
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.FitScore != nil && b.FitScore != nil && *a.FitScore != *b.FitScore {
+			return *a.FitScore > *b.FitScore
+		}
+		if a.Load != nil && b.Load != nil && *a.Load != *b.Load {
+			return *a.Load < *b.Load
+		}
+		return a.ReviewCount > b.ReviewCount
+	})
+
+	best := []models.BestVisitTime{}
+	for _, candidate := range candidates {
+		if len(best) == maxBestVisitTimes {
+			break
+		}
+		overlaps := false
+		for _, chosen := range best {
+			if hoursApart(candidate.HourOfWeek, chosen.HourOfWeek) <= 2*visitWindowHours {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			best = append(best, candidate)
+		}
+	}
+	return best
+}
+
+// addStats returns the statistics of the ratings of both a and b.
+func addStats(a, b *models.SensoryStats) *models.SensoryStats {
+	sum := &models.SensoryStats{
+		PlaceID:   b.PlaceID,
+		Dimension: b.Dimension,
+		Histogram: make([]int64, len(b.Histogram)),
+	}
+	for _, s := range []*models.SensoryStats{a, b} {
+		if s == nil {
+			continue
+		}
+		sum.Count += s.Count
+		sum.Sum += s.Sum
+		sum.SumSquares += s.SumSquares
+		for i, n := range s.Histogram {
+			if i < len(sum.Histogram) {
+				sum.Histogram[i] += n
+			}
+		}
+	}
+	return sum
+}
+
+// load is the mean of the intensity dimensions' mean ratings, nil if none
+// is rated.
+func load(stats map[string]*models.SensoryStats) *float64 {
+	var total float64
+	rated := 0
+	for _, dimension := range intensityDimensions {
+		if s, ok := stats[dimension]; ok && s.Count > 0 {
+			total += s.Mean()
+			rated++
+		}
+	}
+	if rated == 0 {
+		return nil
+	}
+	mean := total / float64(rated)
+	return &mean
+}
+
+// hoursApart is the distance between two hours of the week, which wraps
+// from Sunday night to Monday morning.
+func hoursApart(a, b int) int {
+	d := a - b
+	if d < 0 {
+		d = -d
+	}
+	if d > models.HoursPerWeek-d {
+		d = models.HoursPerWeek - d
+	}
+	return d
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"testing"
+
+	"sensory-navigator/models"
+)
+
+func bucket(hourOfWeek, reviewCount int, stats ...*models.SensoryStats) *models.SensoryTimeBucket {
+	return &models.SensoryTimeBucket{HourOfWeek: hourOfWeek, ReviewCount: reviewCount, Stats: stats}
+}
+
+func TestBestVisitTimes(t *testing.T) {
+	// Sound level ratings of n reviews: all 1, all 3 or all 5
+	calm := func(n int64) *models.SensoryStats { return ratings(models.DimensionSoundLevel, n, 0, 0, 0, 0) }
+	medium := func(n int64) *models.SensoryStats { return ratings(models.DimensionSoundLevel, 0, 0, n, 0, 0) }
+	loud := func(n int64) *models.SensoryStats { return ratings(models.DimensionSoundLevel, 0, 0, 0, 0, n) }
+	quietProfile := []*models.SensoryPreference{pref(models.DimensionSoundLevel, 2, 3)}
+
+	tests := []struct {
+		name    string
+		buckets []*models.SensoryTimeBucket
+		prefs   []*models.SensoryPreference
+		want    []int
+	}{
+		{
+			name: "no buckets",
+			want: []int{},
+		},
+		{
+			name:    "too few reviews in every window",
+			buckets: []*models.SensoryTimeBucket{bucket(10, 1, calm(1)), bucket(11, 1, calm(1)), bucket(40, 2, calm(2))},
+			want:    []int{},
+		},
+		{
+			name:    "no intensity dimension rated",
+			buckets: []*models.SensoryTimeBucket{bucket(10, 3, ratings(models.DimensionAccessibility, 0, 0, 0, 0, 3))},
+			want:    []int{},
+		},
+		{
+			name:    "calmest first",
+			buckets: []*models.SensoryTimeBucket{bucket(10, 3, loud(3)), bucket(50, 3, calm(3)), bucket(90, 3, medium(3))},
+			want:    []int{50, 90, 10},
+		},
+		{
+			name:    "neighbouring hours are pooled",
+			buckets: []*models.SensoryTimeBucket{bucket(10, 1, calm(1)), bucket(11, 1, calm(1)), bucket(12, 1, calm(1))},
+			// Only the middle hour's window has three reviews
+			want: []int{11},
+		},
+		{
+			name:    "overlapping windows recommend one hour",
+			buckets: []*models.SensoryTimeBucket{bucket(10, 3, calm(3)), bucket(11, 3, calm(3)), bucket(50, 3, loud(3))},
+			want:    []int{10, 50},
+		},
+		{
+			name:    "ties keep hour order and at most three are recommended",
+			buckets: []*models.SensoryTimeBucket{bucket(10, 3, calm(3)), bucket(50, 3, calm(3)), bucket(90, 3, calm(3)), bucket(130, 3, calm(3))},
+			want:    []int{10, 50, 90},
+		},
+		{
+			name:    "equal load prefers more reviews",
+			buckets: []*models.SensoryTimeBucket{bucket(10, 3, calm(3)), bucket(50, 4, calm(4))},
+			want:    []int{50, 10},
+		},
+		{
+			name:    "windows wrap around the week",
+			buckets: []*models.SensoryTimeBucket{bucket(0, 1, calm(1)), bucket(models.HoursPerWeek-1, 2, calm(2))},
+			// Both windows hold the same three reviews and overlap
+			want: []int{0},
+		},
+		{
+			name:    "best fit first with a sensory profile",
+			buckets: []*models.SensoryTimeBucket{bucket(10, 3, medium(3)), bucket(50, 3, calm(3))},
+			prefs:   quietProfile,
+			want:    []int{50, 10},
+		},
+		{
+			name:    "equal fit prefers lower load",
+			buckets: []*models.SensoryTimeBucket{bucket(10, 3, loud(3)), bucket(50, 3, medium(3)), bucket(90, 3, calm(3))},
+			prefs:   quietProfile,
+			// Neither 3 nor 5 is within the tolerance of 2
+			want: []int{90, 50, 10},
+		},
+		{
+			name:    "profile dimensions unrated",
+			buckets: []*models.SensoryTimeBucket{bucket(10, 3, ratings(models.DimensionLighting, 3, 0, 0, 0, 0))},
+			prefs:   quietProfile,
+			want:    []int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			best := BestVisitTimes(tt.buckets, tt.prefs)
+			if best == nil {
+				t.Fatal("BestVisitTimes() = nil, want an empty list for JSON")
+			}
+
+			got := make([]int, 0, len(best))
+			for _, b := range best {
+				got = append(got, b.HourOfWeek)
+				if day, hour := models.SplitHourOfWeek(b.HourOfWeek); b.DayOfWeek != day || b.Hour != hour {
+					t.Errorf("hour %d: day %d hour %d, want day %d hour %d", b.HourOfWeek, b.DayOfWeek, b.Hour, day, hour)
+				}
+				if (b.FitScore != nil) != (len(tt.prefs) > 0) {
+					t.Errorf("hour %d: FitScore set = %v with %d preferences", b.HourOfWeek, b.FitScore != nil, len(tt.prefs))
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("BestVisitTimes() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("BestVisitTimes() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -119,12 +119,16 @@ async function handleLogin(e) {
       body: JSON.stringify({ email, password })
     });
     
-    const data = await response.json();
+    let data = await response.json();
     
     if (!response.ok) {
       throw new Error(data.error || 'Ошибка входа');
     }
     
+    if (data.two_factor_required) {
+      data = await completeTwoFactorLogin(data.challenge_token);
+    }
+    
     saveTokens(data.tokens);
     currentUser = data.user;
     showMainScreen();
@@ -134,6 +138,28 @@ async function handleLogin(e) {
   }
 }
 
+// Exchange a login challenge and a one-time code for tokens
+async function completeTwoFactorLogin(challengeToken) {
+  const code = prompt('Введите код из приложения-аутентификатора или код восстановления');
+  if (!code) {
+    throw new Error('Вход отменён');
+  }
+  
+  const response = await fetch(`${API_URL}/auth/login/2fa`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify({ challenge_token: challengeToken, code })
+  });
+  
+  const data = await response.json();
+  
+  if (!response.ok) {
+    throw new Error(data.error || 'Неверный код');
+  }
+  
+  return data;
+}
+
 // Handle registration
 async function handleRegister(e) {
   e.preventDefault();
@@ -152,7 +178,7 @@ async function handleRegister(e) {
     const data = await response.json();
     
     if (!response.ok) {
-      throw new Error(data.error || 'Ошибка регистрации');
+      throw new Error(fieldErrorMessage(data) || data.error || 'Ошибка регистрации');
     }
     
     saveTokens(data.tokens);
@@ -164,6 +190,24 @@ async function handleRegister(e) {
   }
 }
 
+// Password policy messages, keyed by violation code
+const PASSWORD_ERRORS = {
+  too_short: 'Пароль слишком короткий',
+  too_long: 'Пароль слишком длинный',
+  too_weak: 'Пароль слишком простой: сделайте его длиннее или добавьте цифры и символы',
+  contains_personal_info: 'Пароль не должен содержать email или имя',
+  breached: 'Этот пароль встречался в утечках данных, выберите другой'
+};
+
+// Join field-level errors of a response into one message
+function fieldErrorMessage(data) {
+  if (!data.fields) return '';
+  return Object.values(data.fields)
+    .flat()
+    .map(v => PASSWORD_ERRORS[v.code] || v.message)
+    .join('. ');
+}
+
 // Handle forgot password
 async function handleForgotPassword(e) {
   e.preventDefault();
@@ -186,6 +230,20 @@ async function handleForgotPassword(e) {
 
 // Handle logout
 function handleLogout() {
+  // End the session on the server; local logout proceeds regardless
+  if (refreshToken) {
+    const headers = { 'Content-Type': 'application/json' };
+    // Lets the server revoke the access token immediately as well
+    if (accessToken) {
+      headers['Authorization'] = `Bearer ${accessToken}`;
+    }
+    fetch(`${API_URL}/auth/logout`, {
+      method: 'POST',
+      headers,
+      body: JSON.stringify({ refresh_token: refreshToken })
+    }).catch(() => {});
+  }
+
   localStorage.removeItem('accessToken');
   localStorage.removeItem('refreshToken');
   accessToken = null;